@@ -2,8 +2,11 @@ package actions
 
 import (
 	"context"
+	"fmt"
 	log "github.com/charmbracelet/log"
 	"time"
+
+	"github.com/tchaudhry91/algomon/measure"
 )
 
 type Output struct {
@@ -17,6 +20,7 @@ type ActionerMeta struct {
 	Type        string            `json:"type"`
 	Params      map[string]string `json:"params"`
 	EnvOverride map[string]string `json:"env_override"`
+	Headers     map[string]string `json:"headers"`
 }
 
 type ActionMeta struct {
@@ -24,20 +28,114 @@ type ActionMeta struct {
 	Action   string            `json:"action"`
 	Actioner string            `json:"actioner"`
 	Params   map[string]string `json:"params"`
+	// SendResolved sends the action when the check recovers. It defaults to true for the actioners
+	// raising incidents, which must be closed, and to false for the others.
+	SendResolved *bool `json:"send_resolved"`
+	// Retries are made by the dispatcher with an exponential backoff from RetryBackoff, the
+	// actioners making a single attempt
+	Retries      int              `json:"retries"`
+	RetryBackoff measure.Duration `json:"retry_backoff"`
+	// Remediation marks actions that change the monitored systems, guarding their execution
+	Remediation *RemediationPolicy `json:"remediation"`
+	// Group combines the notifications of the checks using this action into one per group
+	Group *GroupPolicy `json:"group"`
+}
+
+// GroupPolicy groups notifications by the given keys, waiting for Wait after the first one of a
+// group before sending them together. Keys are "datasource", "check", "state" or label names.
+type GroupPolicy struct {
+	By   []string         `json:"by"`
+	Wait measure.Duration `json:"wait"`
+}
+
+// ResolvesOnRecovery reports whether the action is sent when its check recovers
+func (a *ActionMeta) ResolvesOnRecovery() bool {
+	if a.SendResolved != nil {
+		return *a.SendResolved
+	}
+	switch a.Actioner {
+	case "pagerduty", "opsgenie", "alertmanager":
+		return true
+	}
+	return false
+}
+
+// SupportsGrouping reports whether actioners of the type render grouped payloads. The others
+// identify what they send by the check of the payload, so they are only given single checks.
+func SupportsGrouping(actionerType string) bool {
+	switch actionerType {
+	case "teams", "slack", "mattermost":
+		return true
+	}
+	return false
+}
+
+// Validate checks that the policy groups by something
+func (p *GroupPolicy) Validate() error {
+	if len(p.By) == 0 {
+		return fmt.Errorf("by cannot be empty")
+	}
+	if p.Wait.Duration < 0 {
+		return fmt.Errorf("wait cannot be negative")
+	}
+	return nil
+}
+
+// RemediationPolicy limits how often a remediation action may run
+type RemediationPolicy struct {
+	MaxExecutions int              `json:"max_executions"`
+	Window        measure.Duration `json:"window"`
+	Cooldown      measure.Duration `json:"cooldown"`
+	DryRun        bool             `json:"dry_run"`
+}
+
+// Validate checks that the limits of the policy are consistent
+func (p *RemediationPolicy) Validate() error {
+	if p.MaxExecutions < 0 {
+		return fmt.Errorf("max_executions cannot be negative")
+	}
+	if p.Window.Duration < 0 || p.Cooldown.Duration < 0 {
+		return fmt.Errorf("window and cooldown cannot be negative")
+	}
+	if p.MaxExecutions > 0 && p.Window.Duration == 0 {
+		return fmt.Errorf("max_executions requires a window")
+	}
+	return nil
+}
+
+var StateFiring = "firing"
+var StateResolved = "resolved"
+
+// DedupKey is the stable identifier of the incident raised for a check
+func DedupKey(checkName string) string {
+	return "algomon:" + checkName
 }
 
 type Actioner interface {
-	Action(ctx context.Context, action string, input string, params map[string]string, workingDir string) (Output, error)
+	Action(ctx context.Context, action string, payload *Payload, params map[string]string, workingDir string) (Output, error)
 }
 
-func Build(meta ActionerMeta, logger *log.Logger) Actioner {
-	if meta.Type == "python" {
+func Build(meta ActionerMeta, templates *Templates, logger *log.Logger) Actioner {
+	switch meta.Type {
+	case "python":
 		return &PythonActioner{
 			VEnv:        meta.Params["venv"],
 			Directory:   meta.Params["directory"],
 			EnvOverride: meta.EnvOverride,
 			logger:      logger,
 		}
+	case "webhook":
+		return newWebhookActioner(meta, templates, logger)
+	case "teams", "slack", "mattermost":
+		return newChatActioner(meta, templates, logger)
+	case "pagerduty":
+		return newPagerDutyActioner(meta, templates, logger)
+	case "opsgenie":
+		return newOpsgenieActioner(meta, templates, logger)
+	case "alertmanager":
+		return newAlertmanagerActioner(meta, templates, logger)
+	case "email":
+		return newEmailActioner(meta, templates, logger)
 	}
 	return nil
 }
@@ -0,0 +1,267 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/charmbracelet/log"
+)
+
+// Alertmanager Actioner, forwards checks as alerts to the Alertmanager v2 API.
+// Alerts are re-sent on every dispatch while the check is firing, and ended on recovery unless
+// the action sets send_resolved to false. The alerts of a check that were sent before a restart
+// are read back from Alertmanager, so they can still be ended.
+type AlertmanagerActioner struct {
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers"`
+	UIURL     string            `json:"ui_url"`
+	Timeout   time.Duration     `json:"timeout"`
+	Params    map[string]string `json:"params"`
+	templates *Templates
+	client    *http.Client
+	logger    *log.Logger
+
+	mu sync.Mutex
+	// firing holds the label sets last sent per check, so they can be ended once they stop firing
+	firing map[string]map[string]map[string]string
+}
+
+func newAlertmanagerActioner(meta ActionerMeta, templates *Templates, logger *log.Logger) *AlertmanagerActioner {
+	aa := &AlertmanagerActioner{
+		URL:       strings.TrimSuffix(meta.Params["url"], "/"),
+		Headers:   meta.Headers,
+		UIURL:     strings.TrimSuffix(meta.Params["ui_url"], "/"),
+		Timeout:   durationParam(meta.Params, "timeout", 10*time.Second, logger),
+		Params:    meta.Params,
+		templates: templates,
+		logger:    logger,
+		firing:    map[string]map[string]map[string]string{},
+	}
+	aa.client = &http.Client{Timeout: aa.Timeout}
+	return aa
+}
+
+type alertmanagerAlert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	StartsAt     *time.Time        `json:"startsAt,omitempty"`
+	EndsAt       *time.Time        `json:"endsAt,omitempty"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+}
+
+// Action posts one alert per violating series of the check, ending the ones that are no longer firing
+func (aa *AlertmanagerActioner) Action(ctx context.Context, action string, payload *Payload, params map[string]string, workingDir string) (Output, error) {
+	out := Output{
+		RC:        -1,
+		Timestamp: time.Now().UTC(),
+	}
+	baseURL := aa.URL
+	if params["url"] != "" {
+		baseURL = strings.TrimSuffix(params["url"], "/")
+	}
+	if baseURL == "" {
+		return out, fmt.Errorf("No url configured for alertmanager actioner")
+	}
+	summary := summarizeAlert(payload, aa.UIURL)
+	if err := summary.applyTemplates(aa.templates, payload, params, aa.Params); err != nil {
+		return out, err
+	}
+	now := out.Timestamp
+
+	current := map[string]map[string]string{}
+	if summary.State != StateResolved {
+		if len(summary.Violations) == 0 {
+			labels := alertmanagerLabels(summary, "")
+			current[labelsKey(labels)] = labels
+		}
+		for _, series := range summary.Violations {
+			labels := alertmanagerLabels(summary, series)
+			current[labelsKey(labels)] = labels
+		}
+	}
+
+	annotations := alertmanagerAnnotations(payload)
+	if summary.CustomTitle != "" {
+		annotations["summary"] = summary.CustomTitle
+	}
+	if summary.Body != "" {
+		annotations["description"] = summary.Body
+	}
+	alerts := []alertmanagerAlert{}
+	for _, labels := range current {
+		alerts = append(alerts, alertmanagerAlert{
+			Labels:       labels,
+			Annotations:  annotations,
+			GeneratorURL: summary.Link,
+		})
+	}
+
+	aa.mu.Lock()
+	previous, known := aa.firing[summary.Check]
+	aa.mu.Unlock()
+	if !known {
+		previous = aa.activeAlerts(ctx, baseURL, summary)
+	}
+	for key, labels := range previous {
+		if _, ok := current[key]; ok {
+			continue
+		}
+		alerts = append(alerts, alertmanagerAlert{
+			Labels:       labels,
+			EndsAt:       &now,
+			GeneratorURL: summary.Link,
+		})
+	}
+	if len(alerts) == 0 {
+		out.RC = 0
+		out.CombinedOut = "No alerts to send"
+		return out, nil
+	}
+
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return out, fmt.Errorf("Error Marshalling alerts to JSON: %v", err)
+	}
+	status, resp, err := send(ctx, aa.client, http.MethodPost, baseURL+"/api/v2/alerts", aa.Headers, body)
+	out.RC = status
+	out.CombinedOut = string(resp)
+	if err != nil {
+		out.Error = err
+		return out, err
+	}
+
+	aa.mu.Lock()
+	if len(current) == 0 {
+		delete(aa.firing, summary.Check)
+	} else {
+		aa.firing[summary.Check] = current
+	}
+	aa.mu.Unlock()
+	return out, nil
+}
+
+// activeAlerts returns the label sets of the alerts of the check active in Alertmanager, for the
+// alerts sent before the actioner was built. If they cannot be read, a resolution still ends the
+// alert raised for checks without violating series.
+func (aa *AlertmanagerActioner) activeAlerts(ctx context.Context, baseURL string, summary alertSummary) map[string]map[string]string {
+	active := map[string]map[string]string{}
+	query := url.Values{"filter": {"check=" + strconv.Quote(summary.Check)}}
+	status, resp, err := send(ctx, aa.client, http.MethodGet, baseURL+"/api/v2/alerts?"+query.Encode(), aa.Headers, nil)
+	alerts := []alertmanagerAlert{}
+	if err == nil {
+		err = json.Unmarshal(resp, &alerts)
+	}
+	if err != nil {
+		aa.logger.Warn("Could not read the active alerts of the check", "check", summary.Check, "status", status, "err", err)
+		if summary.State == StateResolved {
+			labels := alertmanagerLabels(summary, "")
+			active[labelsKey(labels)] = labels
+		}
+		return active
+	}
+	for _, a := range alerts {
+		if a.Labels["check"] == summary.Check {
+			active[labelsKey(a.Labels)] = a.Labels
+		}
+	}
+	return active
+}
+
+// alertmanagerLabels builds the labels of an alert from the check and one of its violating series
+func alertmanagerLabels(summary alertSummary, series string) map[string]string {
+	labels := map[string]string{}
+	if series != "" {
+		parsed, err := parseSeriesLabels(series)
+		if err != nil {
+			labels["series"] = series
+		}
+		for k, v := range parsed {
+			// Labels starting with __ are reserved
+			if !strings.HasPrefix(k, "__") {
+				labels[k] = v
+			}
+		}
+	}
+	labels["alertname"] = summary.Check
+	labels["check"] = summary.Check
+	if summary.Severity != "" {
+		labels["severity"] = summary.Severity
+	}
+	return labels
+}
+
+// alertmanagerAnnotations turns the scalar fields of the algorithm result into annotations
+func alertmanagerAnnotations(payload *Payload) map[string]string {
+	annotations := map[string]string{}
+	result, ok := payload.Result.(map[string]any)
+	if !ok {
+		annotations["output"] = truncate(payload.Output.CombinedOut, 4096)
+		return annotations
+	}
+	for k, v := range result {
+		switch val := v.(type) {
+		case string:
+			annotations[k] = val
+		case float64:
+			annotations[k] = strconv.FormatFloat(val, 'f', -1, 64)
+		case bool:
+			annotations[k] = strconv.FormatBool(val)
+		}
+	}
+	if title, ok := annotations["title"]; ok {
+		annotations["summary"] = title
+	}
+	return annotations
+}
+
+// parseSeriesLabels parses a series as printed by Prometheus, e.g. up{job="caddy", instance="a:80"}
+func parseSeriesLabels(series string) (map[string]string, error) {
+	labels := map[string]string{}
+	open := strings.Index(series, "{")
+	if open < 0 || !strings.HasSuffix(series, "}") {
+		return nil, fmt.Errorf("not a series: %q", series)
+	}
+	if name := strings.TrimSpace(series[:open]); name != "" {
+		labels["__name__"] = name
+	}
+	rest := series[open+1 : len(series)-1]
+	for {
+		rest = strings.TrimLeft(rest, ", ")
+		if rest == "" {
+			return labels, nil
+		}
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("not a series: %q", series)
+		}
+		name := strings.TrimSpace(rest[:eq])
+		quoted, err := strconv.QuotedPrefix(rest[eq+1:])
+		if err != nil {
+			return nil, fmt.Errorf("not a series: %q", series)
+		}
+		value, _ := strconv.Unquote(quoted)
+		labels[name] = value
+		rest = rest[eq+1+len(quoted):]
+	}
+}
+
+func labelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	b := strings.Builder{}
+	for _, k := range keys {
+		b.WriteString(k + "=" + strconv.Quote(labels[k]) + ",")
+	}
+	return b.String()
+}
@@ -0,0 +1,310 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/charmbracelet/log"
+)
+
+var colorFiring = "#D00000"
+var colorResolved = "#2EB67D"
+
+// Chat Actioner, posts a formatted alert to a Teams, Slack or Mattermost incoming webhook
+type ChatActioner struct {
+	Flavour   string            `json:"flavour"`
+	URL       string            `json:"url"`
+	UIURL     string            `json:"ui_url"`
+	Params    map[string]string `json:"params"`
+	Timeout   time.Duration     `json:"timeout"`
+	templates *Templates
+	client    *http.Client
+	logger    *log.Logger
+}
+
+func newChatActioner(meta ActionerMeta, templates *Templates, logger *log.Logger) *ChatActioner {
+	ca := &ChatActioner{
+		Flavour:   meta.Type,
+		URL:       meta.Params["url"],
+		UIURL:     strings.TrimSuffix(meta.Params["ui_url"], "/"),
+		Params:    meta.Params,
+		Timeout:   durationParam(meta.Params, "timeout", 10*time.Second, logger),
+		templates: templates,
+		logger:    logger,
+	}
+	ca.client = &http.Client{Timeout: ca.Timeout}
+	return ca
+}
+
+// alertSummary is the part of a check result that is rendered into notifications
+type alertSummary struct {
+	Check      string
+	State      string
+	Severity   string
+	Title      string
+	Violations []string
+	Link       string
+	// CustomTitle and Body are rendered from the title and body templates of the action
+	CustomTitle string
+	Body        string
+}
+
+// applyTemplates renders the title and body templates set for the action, if any
+func (s *alertSummary) applyTemplates(t *Templates, payload *Payload, params ...map[string]string) error {
+	data := newTemplateData(payload, params[0])
+	title, _, err := t.renderParam("title", "", data, params...)
+	if err != nil {
+		return fmt.Errorf("Error rendering title: %v", err)
+	}
+	body, _, err := t.renderParam("body", "", data, params...)
+	if err != nil {
+		return fmt.Errorf("Error rendering body: %v", err)
+	}
+	s.CustomTitle = strings.TrimSpace(title)
+	s.Body = strings.TrimSpace(body)
+	return nil
+}
+
+func summarizeAlert(payload *Payload, uiURL string) alertSummary {
+	summary := alertSummary{
+		Check:      payload.Check.Name,
+		State:      payload.State,
+		Severity:   payload.Severity(),
+		Title:      payload.Title(),
+		Violations: payload.Violations(),
+	}
+	if summary.State == "" {
+		summary.State = StateFiring
+	}
+	if len(payload.Grouped) > 1 {
+		names := []string{}
+		summary.Violations = []string{}
+		for _, p := range payload.Grouped {
+			names = append(names, p.Check.Name)
+			summary.Violations = append(summary.Violations, p.Violations()...)
+		}
+		summary.Check = strings.Join(names, ", ")
+		summary.Title = fmt.Sprintf("%d checks grouped by %s", len(names), payload.GroupKey)
+		summary.Link = uiURL
+		return summary
+	}
+	if uiURL != "" && summary.Check != "" {
+		summary.Link = fmt.Sprintf("%s/checks/?name=%s", uiURL, url.QueryEscape(summary.Check))
+	}
+	return summary
+}
+
+func (s alertSummary) heading() string {
+	if s.CustomTitle != "" {
+		return s.CustomTitle
+	}
+	heading := fmt.Sprintf("[%s] %s", strings.ToUpper(s.State), s.Check)
+	if s.Title != "" {
+		heading += ": " + s.Title
+	}
+	return heading
+}
+
+func (s alertSummary) color() string {
+	if s.State == StateResolved {
+		return colorResolved
+	}
+	return colorFiring
+}
+
+func (s alertSummary) severity() string {
+	if s.Severity == "" {
+		return "none"
+	}
+	return s.Severity
+}
+
+// Action posts the alert to the configured webhook. The url can be overridden per action through
+// its params, and title and body templates replace the heading and add a text section.
+func (ca *ChatActioner) Action(ctx context.Context, action string, payload *Payload, params map[string]string, workingDir string) (Output, error) {
+	out := Output{
+		RC:        -1,
+		Timestamp: time.Now().UTC(),
+	}
+	webhookURL := ca.URL
+	if params["url"] != "" {
+		webhookURL = params["url"]
+	}
+	if webhookURL == "" {
+		return out, fmt.Errorf("No url configured for %s actioner", ca.Flavour)
+	}
+	summary := summarizeAlert(payload, ca.UIURL)
+	if err := summary.applyTemplates(ca.templates, payload, params, ca.Params); err != nil {
+		return out, err
+	}
+	var message any
+	switch ca.Flavour {
+	case "teams":
+		message = teamsMessage(summary)
+	case "slack":
+		message = slackMessage(summary)
+	case "mattermost":
+		message = mattermostMessage(summary)
+	default:
+		return out, fmt.Errorf("Unknown chat flavour %q", ca.Flavour)
+	}
+	body, err := json.Marshal(message)
+	if err != nil {
+		return out, fmt.Errorf("Error Marshalling message to JSON: %v", err)
+	}
+	status, resp, err := send(ctx, ca.client, http.MethodPost, webhookURL, nil, body)
+	out.RC = status
+	out.CombinedOut = string(resp)
+	if err != nil {
+		out.Error = err
+		return out, err
+	}
+	return out, nil
+}
+
+// teamsMessage renders an Adaptive Card as accepted by Teams workflow and incoming webhooks
+func teamsMessage(s alertSummary) map[string]any {
+	style := "attention"
+	if s.State == StateResolved {
+		style = "good"
+	}
+	body := []map[string]any{
+		{
+			"type":   "TextBlock",
+			"text":   s.heading(),
+			"size":   "Large",
+			"weight": "Bolder",
+			"color":  style,
+			"wrap":   true,
+		},
+		{
+			"type": "FactSet",
+			"facts": []map[string]string{
+				{"title": "Check", "value": s.Check},
+				{"title": "Status", "value": s.State},
+				{"title": "Severity", "value": s.severity()},
+			},
+		},
+	}
+	if s.Body != "" {
+		body = append(body, map[string]any{
+			"type": "TextBlock",
+			"text": s.Body,
+			"wrap": true,
+		})
+	}
+	if len(s.Violations) > 0 {
+		body = append(body, map[string]any{
+			"type": "TextBlock",
+			"text": "Violating series:\n\n- " + strings.Join(s.Violations, "\n- "),
+			"wrap": true,
+		})
+	}
+	card := map[string]any{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    body,
+	}
+	if s.Link != "" {
+		card["actions"] = []map[string]string{
+			{"type": "Action.OpenUrl", "title": "View in algomon", "url": s.Link},
+		}
+	}
+	return map[string]any{
+		"type": "message",
+		"attachments": []map[string]any{
+			{"contentType": "application/vnd.microsoft.card.adaptive", "content": card},
+		},
+	}
+}
+
+// slackHeaderLimit is the maximum length of the text of a Slack header block, longer ones being rejected
+const slackHeaderLimit = 150
+
+// slackMessage renders Block Kit blocks, wrapped in an attachment to get the status color bar. The
+// header is truncated to fit, the full heading being kept in the text of the message.
+func slackMessage(s alertSummary) map[string]any {
+	header := s.heading()
+	if len(header) > slackHeaderLimit {
+		header = truncate(header, slackHeaderLimit-len("…")) + "…"
+	}
+	blocks := []map[string]any{
+		{
+			"type": "header",
+			"text": map[string]string{"type": "plain_text", "text": header},
+		},
+		{
+			"type": "section",
+			"fields": []map[string]string{
+				{"type": "mrkdwn", "text": "*Check:*\n" + s.Check},
+				{"type": "mrkdwn", "text": "*Status:*\n" + s.State},
+				{"type": "mrkdwn", "text": "*Severity:*\n" + s.severity()},
+			},
+		},
+	}
+	if s.Body != "" {
+		blocks = append(blocks, map[string]any{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": s.Body},
+		})
+	}
+	if len(s.Violations) > 0 {
+		blocks = append(blocks, map[string]any{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": "*Violating series:*\n```" + strings.Join(s.Violations, "\n") + "```"},
+		})
+	}
+	if s.Link != "" {
+		blocks = append(blocks, map[string]any{
+			"type": "actions",
+			"elements": []map[string]any{
+				{
+					"type": "button",
+					"text": map[string]string{"type": "plain_text", "text": "View in algomon"},
+					"url":  s.Link,
+				},
+			},
+		})
+	}
+	return map[string]any{
+		"text": s.heading(),
+		"attachments": []map[string]any{
+			{"color": s.color(), "blocks": blocks},
+		},
+	}
+}
+
+// mattermostMessage renders a Slack compatible attachment as supported by Mattermost incoming webhooks
+func mattermostMessage(s alertSummary) map[string]any {
+	attachment := map[string]any{
+		"fallback": s.heading(),
+		"color":    s.color(),
+		"title":    s.heading(),
+		"fields": []map[string]any{
+			{"short": true, "title": "Status", "value": s.State},
+			{"short": true, "title": "Severity", "value": s.severity()},
+		},
+	}
+	if s.Link != "" {
+		attachment["title_link"] = s.Link
+	}
+	text := []string{}
+	if s.Body != "" {
+		text = append(text, s.Body)
+	}
+	if len(s.Violations) > 0 {
+		text = append(text, "**Violating series:**\n```\n"+strings.Join(s.Violations, "\n")+"\n```")
+	}
+	if len(text) > 0 {
+		attachment["text"] = strings.Join(text, "\n\n")
+	}
+	return map[string]any{
+		"attachments": []map[string]any{attachment},
+	}
+}
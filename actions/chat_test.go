@@ -0,0 +1,186 @@
+package actions_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/log"
+	"github.com/tchaudhry91/algomon/actions"
+)
+
+func TestChatGroupedNotification(t *testing.T) {
+	message := map[string]any{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Errorf("Could not decode message:%v", err)
+		}
+	}))
+	defer srv.Close()
+
+	actioner := actions.Build(actions.ActionerMeta{
+		Type:   "mattermost",
+		Params: map[string]string{"url": srv.URL},
+	}, nil, log.Default())
+	first := testPayload(actions.StateFiring, "warning", violationOutput)
+	second := testPayload(actions.StateFiring, "warning", `{"violations": ["{job=\"api\"}"]}`)
+	second.Check.Name = "API Check"
+	payload := *first
+	payload.GroupKey = "datasource=prometheus"
+	payload.Grouped = []*actions.Payload{first, second}
+	if _, err := actioner.Action(context.Background(), "", &payload, nil, t.TempDir()); err != nil {
+		t.Fatalf("Chat action failed:%v", err)
+	}
+	text, _ := json.Marshal(message)
+	for _, expected := range []string{"HTTP Check, API Check", "2 checks grouped by datasource=prometheus", "caddy", "api"} {
+		if !strings.Contains(string(text), expected) {
+			t.Errorf("Expected %s in grouped message: %s", expected, text)
+		}
+	}
+}
+
+// dig returns the value at the given map keys and slice indexes of a decoded JSON message
+func dig(v any, path ...any) any {
+	for _, p := range path {
+		switch key := p.(type) {
+		case string:
+			m, _ := v.(map[string]any)
+			v = m[key]
+		case int:
+			l, _ := v.([]any)
+			if key >= len(l) {
+				return nil
+			}
+			v = l[key]
+		}
+	}
+	return v
+}
+
+func TestChatMessages(t *testing.T) {
+	heading := "[FIRING] HTTP Check: Offset Threshold Violation"
+	link := "http://algomon.local/checks/?name=HTTP+Check"
+	tests := []struct {
+		flavour  string
+		state    string
+		expected map[string][]any
+	}{
+		{"teams", actions.StateFiring, map[string][]any{
+			"message": {"type"},
+			"application/vnd.microsoft.card.adaptive": {"attachments", 0, "contentType"},
+			heading:     {"attachments", 0, "content", "body", 0, "text"},
+			"attention": {"attachments", 0, "content", "body", 0, "color"},
+			"warning":   {"attachments", 0, "content", "body", 1, "facts", 2, "value"},
+			link:        {"attachments", 0, "content", "actions", 0, "url"},
+		}},
+		{"teams", actions.StateResolved, map[string][]any{
+			"good": {"attachments", 0, "content", "body", 0, "color"},
+		}},
+		{"slack", actions.StateFiring, map[string][]any{
+			heading:                {"text"},
+			"#D00000":              {"attachments", 0, "color"},
+			"header":               {"attachments", 0, "blocks", 0, "type"},
+			"*Severity:*\nwarning": {"attachments", 0, "blocks", 1, "fields", 2, "text"},
+			"*Violating series:*\n```{job=\"caddy\"}```": {"attachments", 0, "blocks", 2, "text", "text"},
+			link: {"attachments", 0, "blocks", 3, "elements", 0, "url"},
+		}},
+		{"slack", actions.StateResolved, map[string][]any{
+			"#2EB67D": {"attachments", 0, "color"},
+		}},
+		{"mattermost", actions.StateFiring, map[string][]any{
+			heading:   {"attachments", 0, "title"},
+			link:      {"attachments", 0, "title_link"},
+			"#D00000": {"attachments", 0, "color"},
+			"**Violating series:**\n```\n{job=\"caddy\"}\n```": {"attachments", 0, "text"},
+		}},
+		{"mattermost", actions.StateResolved, map[string][]any{
+			"#2EB67D": {"attachments", 0, "color"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.flavour+"/"+tt.state, func(t *testing.T) {
+			var message any
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+					t.Errorf("Could not decode message:%v", err)
+				}
+			}))
+			defer srv.Close()
+
+			actioner := actions.Build(actions.ActionerMeta{
+				Type:   tt.flavour,
+				Params: map[string]string{"url": srv.URL, "ui_url": "http://algomon.local/"},
+			}, nil, log.Default())
+			payload := testPayload(tt.state, "warning", violationOutput)
+			if _, err := actioner.Action(context.Background(), "", payload, nil, t.TempDir()); err != nil {
+				t.Fatalf("Chat action failed:%v", err)
+			}
+			for expected, path := range tt.expected {
+				if got := dig(message, path...); got != expected {
+					t.Errorf("Expected %q at %v, got %v", expected, path, got)
+				}
+			}
+		})
+	}
+}
+
+func TestChatTemplatesAndURLOverride(t *testing.T) {
+	var message any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/override" {
+			t.Errorf("Expected the url of the action params, got %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&message)
+	}))
+	defer srv.Close()
+
+	actioner := actions.Build(actions.ActionerMeta{
+		Type:   "slack",
+		Params: map[string]string{"url": "http://unused.local", "title": "{{ .Check.Name }} is {{ .State }}"},
+	}, nil, log.Default())
+	params := map[string]string{"url": srv.URL + "/override", "body": "Owned by {{ .Check.Labels.severity }}"}
+	if _, err := actioner.Action(context.Background(), "", testPayload(actions.StateFiring, "warning", violationOutput), params, t.TempDir()); err != nil {
+		t.Fatalf("Chat action failed:%v", err)
+	}
+	if got := dig(message, "text"); got != "HTTP Check is firing" {
+		t.Fatalf("Expected the title template to be rendered, got %v", got)
+	}
+	if got := dig(message, "attachments", 0, "blocks", 2, "text", "text"); got != "Owned by warning" {
+		t.Fatalf("Expected the body template to be rendered, got %v", got)
+	}
+}
+
+func TestSlackTruncatesLongHeadings(t *testing.T) {
+	var message any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&message)
+	}))
+	defer srv.Close()
+
+	payload := testPayload(actions.StateFiring, "warning", violationOutput)
+	payload.GroupKey = "team=sre"
+	names := []string{}
+	for i := 0; i < 30; i++ {
+		member := testPayload(actions.StateFiring, "warning", violationOutput)
+		member.Check.Name = fmt.Sprintf("Latency of the ünïcode service %d", i)
+		names = append(names, member.Check.Name)
+		payload.Grouped = append(payload.Grouped, member)
+	}
+	actioner := actions.Build(actions.ActionerMeta{Type: "slack", Params: map[string]string{"url": srv.URL}}, nil, log.Default())
+	if _, err := actioner.Action(context.Background(), "", payload, nil, t.TempDir()); err != nil {
+		t.Fatalf("Chat action failed:%v", err)
+	}
+	header, _ := dig(message, "attachments", 0, "blocks", 0, "text", "text").(string)
+	if len(header) > 150 || !utf8.ValidString(header) || !strings.HasSuffix(header, "…") {
+		t.Fatalf("Expected a valid header of at most 150 bytes, got %d bytes: %q", len(header), header)
+	}
+	text, _ := dig(message, "text").(string)
+	if !strings.Contains(text, strings.Join(names, ", ")) {
+		t.Fatalf("Expected the full heading in the text, got %q", text)
+	}
+}
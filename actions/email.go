@@ -0,0 +1,211 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	log "github.com/charmbracelet/log"
+)
+
+var defaultEmailSubject = `[{{ .State | upper }}] {{ .Check.Name }}`
+var defaultEmailText = `Check {{ .Check.Name }} is {{ .State }}.
+
+{{ .Output.CombinedOut }}`
+
+// Email Actioner, sends the rendered check output over SMTP
+type EmailActioner struct {
+	Host      string            `json:"host"`
+	Port      string            `json:"port"`
+	Username  string            `json:"username"`
+	Password  string            `json:"password"`
+	StartTLS  bool              `json:"starttls"`
+	From      string            `json:"from"`
+	To        []string          `json:"to"`
+	Params    map[string]string `json:"params"`
+	Timeout   time.Duration     `json:"timeout"`
+	templates *Templates
+	logger    *log.Logger
+}
+
+func newEmailActioner(meta ActionerMeta, templates *Templates, logger *log.Logger) *EmailActioner {
+	ea := &EmailActioner{
+		Host:      meta.Params["host"],
+		Port:      meta.Params["port"],
+		Username:  meta.Params["username"],
+		Password:  meta.Params["password"],
+		StartTLS:  meta.Params["starttls"] != "false",
+		From:      meta.Params["from"],
+		To:        splitList(meta.Params["to"]),
+		Params:    meta.Params,
+		Timeout:   durationParam(meta.Params, "timeout", 30*time.Second, logger),
+		templates: templates,
+		logger:    logger,
+	}
+	if ea.Port == "" {
+		ea.Port = "587"
+	}
+	return ea
+}
+
+// Action renders the subject and bodies and mails them. The recipients and templates can be
+// overridden per action through the to, subject, text and html params, or their _template variants.
+func (ea *EmailActioner) Action(ctx context.Context, action string, payload *Payload, params map[string]string, workingDir string) (Output, error) {
+	out := Output{
+		RC:        -1,
+		Timestamp: time.Now().UTC(),
+	}
+	to := ea.To
+	if params["to"] != "" {
+		to = splitList(params["to"])
+	}
+	if ea.Host == "" || ea.From == "" || len(to) == 0 {
+		return out, fmt.Errorf("Email actioner needs a host, from and to configured")
+	}
+	data := newTemplateData(payload, params)
+	defaults := map[string]string{"subject": defaultEmailSubject, "text": defaultEmailText}
+	rendered := map[string]string{}
+	for _, part := range []string{"subject", "text"} {
+		r, _, err := ea.templates.renderParam(part, defaults[part], data, params, ea.Params)
+		if err != nil {
+			return out, fmt.Errorf("Error rendering email %s: %v", part, err)
+		}
+		rendered[part] = r
+	}
+	// The check output and labels are escaped in the html part
+	html, _, err := ea.templates.renderHTMLParam("html", data, params, ea.Params)
+	if err != nil {
+		return out, fmt.Errorf("Error rendering email html: %v", err)
+	}
+	rendered["html"] = html
+	msg, err := buildMessage(ea.From, to, strings.TrimSpace(rendered["subject"]), rendered["text"], rendered["html"])
+	if err != nil {
+		return out, fmt.Errorf("Error building email: %v", err)
+	}
+	if err := ea.send(ctx, to, msg); err != nil {
+		out.Error = err
+		return out, err
+	}
+	out.RC = 0
+	out.CombinedOut = fmt.Sprintf("Mail sent to %s", strings.Join(to, ", "))
+	return out, nil
+}
+
+func (ea *EmailActioner) send(ctx context.Context, to []string, msg []byte) error {
+	dialer := net.Dialer{Timeout: ea.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ea.Host, ea.Port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(ea.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, ea.Host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ea.StartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("Server %s does not support STARTTLS", ea.Host)
+		}
+		if err := c.StartTLS(&tls.Config{ServerName: ea.Host}); err != nil {
+			return err
+		}
+	}
+	if ea.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", ea.Username, ea.Password, ea.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(ea.From); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// buildMessage assembles a plain text mail, or a multipart/alternative one if there is an html body
+func buildMessage(from string, to []string, subject string, text string, html string) ([]byte, error) {
+	buf := bytes.Buffer{}
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	if html == "" {
+		buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQuotedPrintable(&buf, text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	mw := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(pw, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qw := quotedprintable.NewWriter(w)
+	if _, err := qw.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qw.Close()
+}
+
+func splitList(list string) []string {
+	items := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
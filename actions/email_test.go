@@ -0,0 +1,142 @@
+package actions_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/tchaudhry91/algomon/actions"
+)
+
+// smtpStub accepts a single mail and hands over the envelope recipients and the data
+func smtpStub(t *testing.T) (string, chan []string, chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen:%v", err)
+	}
+	rcpts := make(chan []string, 1)
+	data := make(chan string, 1)
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP stub")
+		to := []string{}
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				to = append(to, strings.Trim(strings.TrimSpace(line)[8:], "<>"))
+				reply("250 OK")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 Go ahead")
+				body := strings.Builder{}
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					body.WriteString(l)
+				}
+				rcpts <- to
+				data <- body.String()
+				reply("250 OK")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 Bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return l.Addr().String(), rcpts, data
+}
+
+func TestEmailAction(t *testing.T) {
+	addr, rcpts, data := smtpStub(t)
+	host, port, _ := net.SplitHostPort(addr)
+	actioner := actions.Build(actions.ActionerMeta{
+		Type: "email",
+		Params: map[string]string{
+			"host":     host,
+			"port":     port,
+			"starttls": "false",
+			"from":     "algomon@example.com",
+			"to":       "ops@example.com, oncall@example.com",
+			"html":     "<h1>{{ .Title }}</h1>",
+		},
+	}, nil, log.Default())
+	payload := testPayload(actions.StateFiring, "", `{"title": "Offset Threshold Violation"}`)
+	if _, err := actioner.Action(context.Background(), "", payload, nil, t.TempDir()); err != nil {
+		t.Fatalf("Email action failed:%v", err)
+	}
+	if to := <-rcpts; len(to) != 2 || to[1] != "oncall@example.com" {
+		t.Fatalf("Unexpected recipients: %v", to)
+	}
+	msg := <-data
+	if !strings.Contains(msg, "Subject: [FIRING] HTTP Check") {
+		t.Fatalf("Subject not rendered: %s", msg)
+	}
+	if !strings.Contains(msg, "multipart/alternative") || !strings.Contains(msg, "<h1>Offset Threshold Violation</h1>") {
+		t.Fatalf("HTML body not rendered: %s", msg)
+	}
+}
+
+func TestEmailEscapesHTML(t *testing.T) {
+	templates, err := actions.NewTemplates(map[string]string{"mail-html": `<p>{{ .Check.Name }}</p><pre>{{ .Output.CombinedOut }}</pre>`}, "")
+	if err != nil {
+		t.Fatalf("Could not parse templates:%v", err)
+	}
+	tests := []struct {
+		name   string
+		params map[string]string
+	}{
+		{"inline", map[string]string{"html": `<pre>{{ .Output.CombinedOut }}</pre>`}},
+		{"named", map[string]string{"html_template": "mail-html"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, _, data := smtpStub(t)
+			host, port, _ := net.SplitHostPort(addr)
+			params := map[string]string{
+				"host":     host,
+				"port":     port,
+				"starttls": "false",
+				"from":     "algomon@example.com",
+				"to":       "ops@example.com",
+				"text":     "{{ .Output.CombinedOut }}",
+			}
+			for k, v := range tt.params {
+				params[k] = v
+			}
+			actioner := actions.Build(actions.ActionerMeta{Type: "email", Params: params}, templates, log.Default())
+			payload := testPayload(actions.StateFiring, "", `<script>alert(1)</script>`)
+			if _, err := actioner.Action(context.Background(), "", payload, nil, t.TempDir()); err != nil {
+				t.Fatalf("Email action failed:%v", err)
+			}
+			msg := <-data
+			html := msg[strings.Index(msg, "text/html"):]
+			if strings.Contains(html, "<script>") || !strings.Contains(html, "&lt;script&gt;") {
+				t.Fatalf("Expected the output to be escaped in the HTML part: %s", html)
+			}
+			text := msg[strings.Index(msg, "text/plain"):strings.Index(msg, "text/html")]
+			if !strings.Contains(text, "<script>") {
+				t.Fatalf("Expected the output unescaped in the text part: %s", text)
+			}
+		})
+	}
+}
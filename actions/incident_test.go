@@ -0,0 +1,246 @@
+package actions_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/log"
+	"github.com/tchaudhry91/algomon/actions"
+)
+
+var violationOutput = `{"title": "Offset Threshold Violation", "violations": ["{job=\"caddy\"}"]}`
+
+func testPayload(state string, severity string, combinedOut string) *actions.Payload {
+	payload := &actions.Payload{
+		State: state,
+		Check: actions.CheckDefinition{
+			Name:   "HTTP Check",
+			Labels: map[string]string{"severity": severity},
+		},
+		Output: actions.CheckOutput{CombinedOut: combinedOut, RC: 2},
+	}
+	payload.DecodeResult()
+	return payload
+}
+
+func TestPagerDutyTriggerAndResolve(t *testing.T) {
+	events := []map[string]any{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event := map[string]any{}
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("Could not decode event:%v", err)
+		}
+		events = append(events, event)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	actioner := actions.Build(actions.ActionerMeta{
+		Type:   "pagerduty",
+		Params: map[string]string{"url": srv.URL, "routing_key": "key"},
+	}, nil, log.Default())
+	for _, state := range []string{actions.StateFiring, actions.StateResolved} {
+		if _, err := actioner.Action(context.Background(), "", testPayload(state, "critical", violationOutput), nil, t.TempDir()); err != nil {
+			t.Fatalf("PagerDuty action failed:%v", err)
+		}
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	if events[0]["event_action"] != "trigger" || events[1]["event_action"] != "resolve" {
+		t.Fatalf("Unexpected event actions: %v, %v", events[0]["event_action"], events[1]["event_action"])
+	}
+	if events[0]["dedup_key"] != events[1]["dedup_key"] || events[0]["dedup_key"] != actions.DedupKey("HTTP Check") {
+		t.Fatalf("Dedup keys do not match: %v, %v", events[0]["dedup_key"], events[1]["dedup_key"])
+	}
+	payload := events[0]["payload"].(map[string]any)
+	if payload["severity"] != "critical" {
+		t.Fatalf("Unexpected severity: %v", payload["severity"])
+	}
+}
+
+func TestOpsgenieCreateAndClose(t *testing.T) {
+	requests := []*http.Request{}
+	aliases := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "GenieKey key" {
+			t.Errorf("Missing API key")
+		}
+		body := map[string]any{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if alias, ok := body["alias"].(string); ok {
+			aliases = append(aliases, alias)
+		}
+		requests = append(requests, r)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	actioner := actions.Build(actions.ActionerMeta{
+		Type:   "opsgenie",
+		Params: map[string]string{"url": srv.URL, "api_key": "key"},
+	}, nil, log.Default())
+	for _, state := range []string{actions.StateFiring, actions.StateResolved} {
+		if _, err := actioner.Action(context.Background(), "", testPayload(state, "", violationOutput), nil, t.TempDir()); err != nil {
+			t.Fatalf("Opsgenie action failed:%v", err)
+		}
+	}
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(requests))
+	}
+	if requests[0].URL.Path != "/v2/alerts" || len(aliases) != 1 || aliases[0] != actions.DedupKey("HTTP Check") {
+		t.Fatalf("Unexpected create request: %s %v", requests[0].URL.Path, aliases)
+	}
+	if requests[1].URL.Path != "/v2/alerts/"+actions.DedupKey("HTTP Check")+"/close" || requests[1].URL.Query().Get("identifierType") != "alias" {
+		t.Fatalf("Unexpected close request: %s", requests[1].URL.String())
+	}
+}
+
+func TestAlertmanagerFiringAndResolved(t *testing.T) {
+	posts := [][]map[string]any{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/alerts" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.Method == http.MethodGet {
+			w.Write([]byte("[]"))
+			return
+		}
+		alerts := []map[string]any{}
+		if err := json.NewDecoder(r.Body).Decode(&alerts); err != nil {
+			t.Errorf("Could not decode alerts:%v", err)
+		}
+		posts = append(posts, alerts)
+	}))
+	defer srv.Close()
+
+	actioner := actions.Build(actions.ActionerMeta{
+		Type:   "alertmanager",
+		Params: map[string]string{"url": srv.URL},
+	}, nil, log.Default())
+	for _, state := range []string{actions.StateFiring, actions.StateResolved} {
+		if _, err := actioner.Action(context.Background(), "", testPayload(state, "warning", violationOutput), nil, t.TempDir()); err != nil {
+			t.Fatalf("Alertmanager action failed:%v", err)
+		}
+	}
+	if len(posts) != 2 || len(posts[0]) != 1 || len(posts[1]) != 1 {
+		t.Fatalf("Expected one alert per post, got %v", posts)
+	}
+	labels := posts[0][0]["labels"].(map[string]any)
+	if labels["alertname"] != "HTTP Check" || labels["severity"] != "warning" || labels["job"] != "caddy" {
+		t.Fatalf("Unexpected labels: %v", labels)
+	}
+	if posts[0][0]["annotations"].(map[string]any)["summary"] != "Offset Threshold Violation" {
+		t.Fatalf("Unexpected annotations: %v", posts[0][0]["annotations"])
+	}
+	if _, ok := posts[1][0]["endsAt"]; !ok {
+		t.Fatalf("Expected the resolved alert to have endsAt set")
+	}
+	if posts[1][0]["labels"].(map[string]any)["job"] != "caddy" {
+		t.Fatalf("Resolved alert does not match the firing one: %v", posts[1][0]["labels"])
+	}
+}
+
+func TestAlertmanagerResolvesAfterRestart(t *testing.T) {
+	tests := []struct {
+		name     string
+		active   string
+		expected []string
+	}{
+		{"active alerts", `[{"labels": {"alertname": "HTTP Check", "check": "HTTP Check", "job": "caddy"}}, {"labels": {"alertname": "HTTP Check", "check": "HTTP Check", "job": "nginx"}}]`, []string{"caddy", "nginx"}},
+		{"no active alerts", `[]`, nil},
+		{"unreadable alerts", ``, []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ended := []string{}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					if r.URL.Query().Get("filter") != `check="HTTP Check"` {
+						t.Errorf("Unexpected filter %q", r.URL.Query().Get("filter"))
+					}
+					if tt.active == "" {
+						w.WriteHeader(http.StatusInternalServerError)
+					}
+					w.Write([]byte(tt.active))
+					return
+				}
+				alerts := []map[string]any{}
+				json.NewDecoder(r.Body).Decode(&alerts)
+				for _, a := range alerts {
+					if _, ok := a["endsAt"]; !ok {
+						t.Errorf("Expected only ended alerts, got %v", a)
+					}
+					job, _ := a["labels"].(map[string]any)["job"].(string)
+					ended = append(ended, job)
+				}
+			}))
+			defer srv.Close()
+
+			// A new actioner knows nothing of the alerts sent before
+			actioner := actions.Build(actions.ActionerMeta{
+				Type:   "alertmanager",
+				Params: map[string]string{"url": srv.URL},
+			}, nil, log.Default())
+			if _, err := actioner.Action(context.Background(), "", testPayload(actions.StateResolved, "warning", violationOutput), nil, t.TempDir()); err != nil {
+				t.Fatalf("Alertmanager action failed:%v", err)
+			}
+			sort.Strings(ended)
+			if strings.Join(ended, ",") != strings.Join(tt.expected, ",") {
+				t.Fatalf("Expected the alerts of %v to be ended, got %v", tt.expected, ended)
+			}
+		})
+	}
+}
+
+func TestOpsgenieTruncatesOnRuneBoundaries(t *testing.T) {
+	messages := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]any{}
+		json.NewDecoder(r.Body).Decode(&body)
+		messages = append(messages, body["message"].(string))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	actioner := actions.Build(actions.ActionerMeta{
+		Type:   "opsgenie",
+		Params: map[string]string{"url": srv.URL, "api_key": "key"},
+	}, nil, log.Default())
+	payload := testPayload(actions.StateFiring, "", violationOutput)
+	payload.Check.Name = strings.Repeat("é", 100)
+	if _, err := actioner.Action(context.Background(), "", payload, nil, t.TempDir()); err != nil {
+		t.Fatalf("Opsgenie action failed:%v", err)
+	}
+	if len(messages) != 1 || len(messages[0]) > 130 || !utf8.ValidString(messages[0]) {
+		t.Fatalf("Expected a valid message of at most 130 bytes, got %q", messages)
+	}
+}
+
+func TestResolvesOnRecovery(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		actioner     string
+		sendResolved *bool
+		expected     bool
+	}{
+		{"pagerduty", nil, true},
+		{"opsgenie", nil, true},
+		{"opsgenie", &no, false},
+		{"alertmanager", nil, true},
+		{"slack", nil, false},
+		{"slack", &yes, true},
+	}
+	for _, tt := range tests {
+		a := actions.ActionMeta{Actioner: tt.actioner, SendResolved: tt.sendResolved}
+		if a.ResolvesOnRecovery() != tt.expected {
+			t.Fatalf("Expected %s with send_resolved %v to resolve %v", tt.actioner, tt.sendResolved, tt.expected)
+		}
+	}
+}
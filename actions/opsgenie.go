@@ -0,0 +1,154 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	log "github.com/charmbracelet/log"
+)
+
+var defaultOpsgenieURL = "https://api.opsgenie.com"
+
+// Opsgenie Actioner, creates and closes alerts through the Alert API.
+// Alerts are closed on recovery unless the action sets send_resolved to false.
+type OpsgenieActioner struct {
+	URL       string            `json:"url"`
+	APIKey    string            `json:"api_key"`
+	UIURL     string            `json:"ui_url"`
+	Timeout   time.Duration     `json:"timeout"`
+	Params    map[string]string `json:"params"`
+	templates *Templates
+	client    *http.Client
+	logger    *log.Logger
+}
+
+func newOpsgenieActioner(meta ActionerMeta, templates *Templates, logger *log.Logger) *OpsgenieActioner {
+	oa := &OpsgenieActioner{
+		URL:       strings.TrimSuffix(meta.Params["url"], "/"),
+		APIKey:    meta.Params["api_key"],
+		UIURL:     strings.TrimSuffix(meta.Params["ui_url"], "/"),
+		Timeout:   durationParam(meta.Params, "timeout", 10*time.Second, logger),
+		Params:    meta.Params,
+		templates: templates,
+		logger:    logger,
+	}
+	if oa.URL == "" {
+		oa.URL = defaultOpsgenieURL
+	}
+	oa.client = &http.Client{Timeout: oa.Timeout}
+	return oa
+}
+
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Priority    string            `json:"priority"`
+	Source      string            `json:"source"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+}
+
+type opsgenieClose struct {
+	Source string `json:"source"`
+	Note   string `json:"note"`
+}
+
+// Action creates an alert while the check is firing and closes it once it recovers.
+// The api_key and priority can be overridden per action through its params.
+func (oa *OpsgenieActioner) Action(ctx context.Context, action string, payload *Payload, params map[string]string, workingDir string) (Output, error) {
+	out := Output{
+		RC:        -1,
+		Timestamp: time.Now().UTC(),
+	}
+	apiKey := oa.APIKey
+	if params["api_key"] != "" {
+		apiKey = params["api_key"]
+	}
+	if apiKey == "" {
+		return out, fmt.Errorf("No api_key configured for opsgenie actioner")
+	}
+	headers := map[string]string{"Authorization": "GenieKey " + apiKey}
+	summary := summarizeAlert(payload, oa.UIURL)
+	if err := summary.applyTemplates(oa.templates, payload, params, oa.Params); err != nil {
+		return out, err
+	}
+	alias := DedupKey(summary.Check)
+
+	var endpoint string
+	var request any
+	if summary.State == StateResolved {
+		endpoint = fmt.Sprintf("%s/v2/alerts/%s/close?identifierType=alias", oa.URL, url.PathEscape(alias))
+		request = opsgenieClose{Source: "algomon", Note: "Check recovered"}
+	} else {
+		endpoint = oa.URL + "/v2/alerts"
+		priority := params["priority"]
+		if priority == "" {
+			priority = opsgeniePriority(summary.Severity)
+		}
+		alert := opsgenieAlert{
+			Message:  truncate(summary.heading(), 130),
+			Alias:    alias,
+			Priority: priority,
+			Source:   "algomon",
+			Tags:     []string{"algomon"},
+			Details:  map[string]string{"check": summary.Check},
+		}
+		description := payload.Output.CombinedOut
+		if summary.Body != "" {
+			description = summary.Body
+		} else if len(summary.Violations) > 0 {
+			description = "Violating series:\n" + strings.Join(summary.Violations, "\n")
+		}
+		if summary.Link != "" {
+			description += "\n\n" + summary.Link
+			alert.Details["link"] = summary.Link
+		}
+		alert.Description = truncate(description, 15000)
+		request = alert
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return out, fmt.Errorf("Error Marshalling alert to JSON: %v", err)
+	}
+	status, resp, err := send(ctx, oa.client, http.MethodPost, endpoint, headers, body)
+	out.RC = status
+	out.CombinedOut = string(resp)
+	if err != nil {
+		out.Error = err
+		return out, err
+	}
+	return out, nil
+}
+
+// opsgeniePriority maps the check severity onto an Opsgenie priority
+func opsgeniePriority(severity string) string {
+	switch strings.ToLower(severity) {
+	case "critical", "page":
+		return "P1"
+	case "error":
+		return "P2"
+	case "warning", "warn":
+		return "P3"
+	case "info":
+		return "P5"
+	}
+	return "P3"
+}
+
+// truncate cuts s down to at most max bytes, without splitting a rune
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
@@ -0,0 +1,140 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/charmbracelet/log"
+)
+
+var defaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDuty Actioner, triggers and resolves incidents through the Events API v2.
+// Incidents are resolved on recovery unless the action sets send_resolved to false.
+type PagerDutyActioner struct {
+	URL        string            `json:"url"`
+	RoutingKey string            `json:"routing_key"`
+	Source     string            `json:"source"`
+	UIURL      string            `json:"ui_url"`
+	Timeout    time.Duration     `json:"timeout"`
+	Params     map[string]string `json:"params"`
+	templates  *Templates
+	client     *http.Client
+	logger     *log.Logger
+}
+
+func newPagerDutyActioner(meta ActionerMeta, templates *Templates, logger *log.Logger) *PagerDutyActioner {
+	pa := &PagerDutyActioner{
+		URL:        meta.Params["url"],
+		RoutingKey: meta.Params["routing_key"],
+		Source:     meta.Params["source"],
+		UIURL:      strings.TrimSuffix(meta.Params["ui_url"], "/"),
+		Timeout:    durationParam(meta.Params, "timeout", 10*time.Second, logger),
+		Params:     meta.Params,
+		templates:  templates,
+		logger:     logger,
+	}
+	if pa.URL == "" {
+		pa.URL = defaultPagerDutyURL
+	}
+	if pa.Source == "" {
+		pa.Source = "algomon"
+	}
+	pa.client = &http.Client{Timeout: pa.Timeout}
+	return pa
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+	Links       []pagerDutyLink   `json:"links,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string         `json:"summary"`
+	Source        string         `json:"source"`
+	Severity      string         `json:"severity"`
+	CustomDetails map[string]any `json:"custom_details,omitempty"`
+}
+
+type pagerDutyLink struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+// Action triggers an incident while the check is firing and resolves it once it recovers.
+// The routing_key can be overridden per action through its params.
+func (pa *PagerDutyActioner) Action(ctx context.Context, action string, payload *Payload, params map[string]string, workingDir string) (Output, error) {
+	out := Output{
+		RC:        -1,
+		Timestamp: time.Now().UTC(),
+	}
+	routingKey := pa.RoutingKey
+	if params["routing_key"] != "" {
+		routingKey = params["routing_key"]
+	}
+	if routingKey == "" {
+		return out, fmt.Errorf("No routing_key configured for pagerduty actioner")
+	}
+	summary := summarizeAlert(payload, pa.UIURL)
+	if err := summary.applyTemplates(pa.templates, payload, params, pa.Params); err != nil {
+		return out, err
+	}
+	event := pagerDutyEvent{
+		RoutingKey:  routingKey,
+		EventAction: "trigger",
+		DedupKey:    DedupKey(summary.Check),
+	}
+	if summary.State == StateResolved {
+		event.EventAction = "resolve"
+	} else {
+		event.Payload = &pagerDutyPayload{
+			Summary:  summary.heading(),
+			Source:   pa.Source,
+			Severity: pagerDutySeverity(summary.Severity),
+			CustomDetails: map[string]any{
+				"check":      summary.Check,
+				"violations": summary.Violations,
+				"output":     payload.Output.CombinedOut,
+				"inputs":     payload.Inputs,
+			},
+		}
+		if summary.Body != "" {
+			event.Payload.CustomDetails["description"] = summary.Body
+		}
+		if summary.Link != "" {
+			event.Links = []pagerDutyLink{{Href: summary.Link, Text: "View in algomon"}}
+		}
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return out, fmt.Errorf("Error Marshalling event to JSON: %v", err)
+	}
+	status, resp, err := send(ctx, pa.client, http.MethodPost, pa.URL, nil, body)
+	out.RC = status
+	out.CombinedOut = string(resp)
+	if err != nil {
+		out.Error = err
+		return out, err
+	}
+	return out, nil
+}
+
+// pagerDutySeverity maps the check severity onto the levels accepted by PagerDuty
+func pagerDutySeverity(severity string) string {
+	switch strings.ToLower(severity) {
+	case "critical", "error", "warning", "info":
+		return strings.ToLower(severity)
+	case "page":
+		return "critical"
+	case "warn":
+		return "warning"
+	}
+	return "error"
+}
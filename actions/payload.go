@@ -0,0 +1,82 @@
+package actions
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/tchaudhry91/algomon/measure"
+)
+
+// Payload is everything an action is told about the check it is dispatched for
+type Payload struct {
+	ActionName     string                    `json:"action_name"`
+	State          string                    `json:"state"`
+	PreviousStatus string                    `json:"previous_status"`
+	Check          CheckDefinition           `json:"check"`
+	Inputs         map[string]measure.Result `json:"inputs"`
+	Output         CheckOutput               `json:"output"`
+	// Result is the decoded algorithm output, if it printed JSON
+	Result any `json:"result"`
+	// GroupKey and Grouped are set when the action groups its notifications, Grouped holding
+	// the payloads of every check notified together, this one included
+	GroupKey string     `json:"group_key,omitempty"`
+	Grouped  []*Payload `json:"grouped,omitempty"`
+}
+
+// CheckDefinition mirrors the definition of the check that triggered the action
+type CheckDefinition struct {
+	Name            string                `json:"name"`
+	Labels          map[string]string     `json:"labels"`
+	Inputs          []measure.Measurement `json:"inputs"`
+	AlgorithmerType string                `json:"algorithmer_type"`
+	Algorithm       string                `json:"algorithm"`
+	AlgorithmParams map[string]string     `json:"algorithm_params"`
+	Interval        measure.Duration      `json:"interval"`
+}
+
+// CheckOutput mirrors the output of the check run that triggered the action
+type CheckOutput struct {
+	Status      string    `json:"status"`
+	Timestamp   time.Time `json:"timestamp"`
+	CombinedOut string    `json:"combined_out"`
+	RC          int       `json:"rc"`
+	Error       string    `json:"error"`
+}
+
+// DecodeResult decodes the combined output of the algorithm into Result, if it is JSON
+func (p *Payload) DecodeResult() {
+	var result any
+	if err := json.Unmarshal([]byte(p.Output.CombinedOut), &result); err == nil {
+		p.Result = result
+	}
+}
+
+// Severity of the check, taken from its severity label
+func (p *Payload) Severity() string {
+	return p.Check.Labels["severity"]
+}
+
+// Title printed by the algorithm, if any
+func (p *Payload) Title() string {
+	if result, ok := p.Result.(map[string]any); ok {
+		if title, ok := result["title"].(string); ok {
+			return title
+		}
+	}
+	return ""
+}
+
+// Violations are the violating series printed by the algorithm, if any
+func (p *Payload) Violations() []string {
+	violations := []string{}
+	if result, ok := p.Result.(map[string]any); ok {
+		if list, ok := result["violations"].([]any); ok {
+			for _, v := range list {
+				if series, ok := v.(string); ok {
+					violations = append(violations, series)
+				}
+			}
+		}
+	}
+	return violations
+}
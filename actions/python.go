@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"syscall"
 	"time"
 )
 
@@ -19,7 +20,7 @@ type PythonActioner struct {
 	logger      *log.Logger
 }
 
-func (pa *PythonActioner) Action(ctx context.Context, action string, input string, params map[string]string, workingDir string) (Output, error) {
+func (pa *PythonActioner) Action(ctx context.Context, action string, payload *Payload, params map[string]string, workingDir string) (Output, error) {
 	out := Output{
 		RC:          -1,
 		CombinedOut: "",
@@ -35,7 +36,11 @@ func (pa *PythonActioner) Action(ctx context.Context, action string, input strin
 	if err != nil {
 		return out, fmt.Errorf("Error Marshalling Params to JSON: %v", err)
 	}
-	err = os.WriteFile(path.Join(workingDir, "inputs.json"), []byte(input), 0644)
+	inputsData, err := json.Marshal(payload)
+	if err != nil {
+		return out, fmt.Errorf("Error Marshalling Payload to JSON: %v", err)
+	}
+	err = os.WriteFile(path.Join(workingDir, "inputs.json"), inputsData, 0644)
 	if err != nil {
 		return out, fmt.Errorf("Error writing inputs file: %v", err)
 	}
@@ -51,6 +56,12 @@ func (pa *PythonActioner) Action(ctx context.Context, action string, input strin
 	cmd.Dir = workingDir
 	cmd.Env = os.Environ()
 	cmd.Env = append(cmd.Env, envMapToSlice(pa.EnvOverride)...)
+	// Kill the whole process group on timeout, so the interpreter does not outlive the shell
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
 
 	combined, err := cmd.CombinedOutput()
 	if err != nil {
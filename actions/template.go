@@ -0,0 +1,258 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Templates is the set of named templates shared by all actioners. Actions reference them by
+// name through params ending in _template, e.g. "body_template": "teams-body".
+type Templates struct {
+	set *template.Template
+	// htmlSet holds the same templates for rendering HTML, escaping the data rendered into them. It is
+	// never executed itself, only its clones, so that it can still be cloned.
+	htmlSet     *htmltemplate.Template
+	externalURL string
+}
+
+// NewTemplates parses the named template definitions, they can include each other with {{ template "name" . }}
+func NewTemplates(defs map[string]string, externalURL string) (*Templates, error) {
+	t := &Templates{externalURL: strings.TrimSuffix(externalURL, "/")}
+	t.set = template.New("").Funcs(t.funcs()).Option("missingkey=zero")
+	t.htmlSet = htmltemplate.New("").Funcs(htmltemplate.FuncMap(t.funcs())).Option("missingkey=zero")
+	for name, text := range defs {
+		if _, err := t.set.New(name).Parse(text); err != nil {
+			return nil, fmt.Errorf("invalid template %q: %v", name, err)
+		}
+		if _, err := t.htmlSet.New(name).Parse(text); err != nil {
+			return nil, fmt.Errorf("invalid template %q: %v", name, err)
+		}
+	}
+	return t, nil
+}
+
+// Has reports whether a template of the given name is defined
+func (t *Templates) Has(name string) bool {
+	return t != nil && t.set.Lookup(name) != nil
+}
+
+// Render executes the named template against data
+func (t *Templates) Render(name string, data any) (string, error) {
+	if !t.Has(name) {
+		return "", fmt.Errorf("template %q is not defined", name)
+	}
+	buf := bytes.Buffer{}
+	if err := t.set.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderText parses and executes an inline template, which can make use of the named ones
+func (t *Templates) RenderText(name string, text string, data any) (string, error) {
+	var tmpl *template.Template
+	if t == nil {
+		tmpl = template.New(name).Funcs((&Templates{}).funcs()).Option("missingkey=zero")
+	} else {
+		clone, err := t.set.Clone()
+		if err != nil {
+			return "", err
+		}
+		tmpl = clone.New(name)
+	}
+	if _, err := tmpl.Parse(text); err != nil {
+		return "", err
+	}
+	buf := bytes.Buffer{}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// renderHTMLParam is renderParam for HTML, the data rendered into the template being escaped
+func (t *Templates) renderHTMLParam(key string, data any, params ...map[string]string) (string, bool, error) {
+	var tmpl *htmltemplate.Template
+	if t == nil {
+		tmpl = htmltemplate.New("").Funcs(htmltemplate.FuncMap((&Templates{}).funcs())).Option("missingkey=zero")
+	} else {
+		clone, err := t.htmlSet.Clone()
+		if err != nil {
+			return "", false, err
+		}
+		tmpl = clone
+	}
+	name := ""
+	for _, p := range params {
+		if name = p[key+"_template"]; name != "" {
+			if tmpl.Lookup(name) == nil {
+				return "", true, fmt.Errorf("template %q is not defined", name)
+			}
+			break
+		}
+		if p[key] != "" {
+			name = key
+			if _, err := tmpl.New(key).Parse(p[key]); err != nil {
+				return "", true, err
+			}
+			break
+		}
+	}
+	if name == "" {
+		return "", false, nil
+	}
+	buf := bytes.Buffer{}
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", true, err
+	}
+	return buf.String(), true, nil
+}
+
+// Parse checks that an inline template parses, without executing it
+func (t *Templates) Parse(name string, text string) error {
+	var tmpl *template.Template
+	if t == nil {
+		tmpl = template.New(name).Funcs((&Templates{}).funcs())
+	} else {
+		clone, err := t.set.Clone()
+		if err != nil {
+			return err
+		}
+		tmpl = clone.New(name)
+	}
+	_, err := tmpl.Parse(text)
+	return err
+}
+
+// renderParam renders the template asked for under key by the first of the params that sets it,
+// either a named template referenced by key_template or an inline template in key. The fallback
+// text is used otherwise. It returns false if there was nothing to render.
+func (t *Templates) renderParam(key string, fallback string, data any, params ...map[string]string) (string, bool, error) {
+	text := fallback
+	for _, p := range params {
+		if name := p[key+"_template"]; name != "" {
+			out, err := t.Render(name, data)
+			return out, true, err
+		}
+		if p[key] != "" {
+			text = p[key]
+			break
+		}
+	}
+	if text == "" {
+		return "", false, nil
+	}
+	out, err := t.RenderText(key, text, data)
+	return out, true, err
+}
+
+func (t *Templates) funcs() template.FuncMap {
+	return template.FuncMap{
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"join": func(sep string, items []string) string {
+			return strings.Join(items, sep)
+		},
+		"json": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+		"default": func(def any, v any) any {
+			if v == nil || v == "" {
+				return def
+			}
+			return v
+		},
+		"truncate": func(n int, s string) string {
+			return truncate(s, n)
+		},
+		"humanize":         humanize,
+		"humanizeDuration": humanizeDuration,
+		"labels": func(series string) map[string]string {
+			labels, _ := parseSeriesLabels(series)
+			return labels
+		},
+		"label": func(name string, series string) string {
+			labels, _ := parseSeriesLabels(series)
+			return labels[name]
+		},
+		"link": func(checkName string) string {
+			if t.externalURL == "" {
+				return ""
+			}
+			return fmt.Sprintf("%s/checks/?name=%s", t.externalURL, url.QueryEscape(checkName))
+		},
+	}
+}
+
+// humanize formats a number with an SI suffix, e.g. 1234567 as 1.235M
+func humanize(v any) string {
+	f, ok := toFloat(v)
+	if !ok {
+		return fmt.Sprint(v)
+	}
+	if f == 0 || math.IsNaN(f) || math.IsInf(f, 0) || math.Abs(f) < 1 {
+		return strconv.FormatFloat(f, 'g', 4, 64)
+	}
+	prefixes := []string{"", "k", "M", "G", "T", "P", "E"}
+	i := 0
+	for math.Abs(f) >= 1000 && i < len(prefixes)-1 {
+		f /= 1000
+		i++
+	}
+	return strconv.FormatFloat(f, 'g', 4, 64) + prefixes[i]
+}
+
+// humanizeDuration formats a number of seconds or a duration string, e.g. 3725 as 1h2m5s
+func humanizeDuration(v any) string {
+	if s, ok := v.(string); ok {
+		if d, err := time.ParseDuration(s); err == nil {
+			return d.String()
+		}
+	}
+	f, ok := toFloat(v)
+	if !ok {
+		return fmt.Sprint(v)
+	}
+	return time.Duration(f * float64(time.Second)).Round(time.Millisecond).String()
+}
+
+func toFloat(v any) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case float32:
+		return float64(val), true
+	case int:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case time.Duration:
+		return val.Seconds(), true
+	case string:
+		f, err := strconv.ParseFloat(val, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// templateData is what templates are rendered against, the payload with the params of the action
+type templateData struct {
+	*Payload
+	Params map[string]string `json:"params"`
+}
+
+func newTemplateData(payload *Payload, params map[string]string) templateData {
+	return templateData{
+		Payload: payload,
+		Params:  params,
+	}
+}
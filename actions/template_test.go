@@ -0,0 +1,35 @@
+package actions_test
+
+import (
+	"testing"
+
+	"github.com/tchaudhry91/algomon/actions"
+)
+
+func TestTemplatesRender(t *testing.T) {
+	templates, err := actions.NewTemplates(map[string]string{
+		"series": `{{ label "instance" . }}`,
+		"body":   `{{ humanize .count }} requests on {{ template "series" .series }}, see {{ link .check }}`,
+	}, "http://algomon.local/")
+	if err != nil {
+		t.Fatalf("Could not parse templates:%v", err)
+	}
+	out, err := templates.Render("body", map[string]any{
+		"count":  1234567,
+		"series": `{instance="web-1:80", job="caddy"}`,
+		"check":  "HTTP Check",
+	})
+	if err != nil {
+		t.Fatalf("Could not render template:%v", err)
+	}
+	expected := "1.235M requests on web-1:80, see http://algomon.local/checks/?name=HTTP+Check"
+	if out != expected {
+		t.Fatalf("Rendered %q, expected %q", out, expected)
+	}
+	if _, err := templates.Render("missing", nil); err == nil {
+		t.Fatalf("Expected undefined template to fail")
+	}
+	if _, err := actions.NewTemplates(map[string]string{"broken": "{{ .Check "}, ""); err == nil {
+		t.Fatalf("Expected invalid template to fail parsing")
+	}
+}
@@ -0,0 +1,107 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	log "github.com/charmbracelet/log"
+)
+
+var defaultWebhookBody = `{{ json .Payload }}`
+
+// Webhook Actioner, sends a templated JSON body to a URL
+type WebhookActioner struct {
+	URL       string            `json:"url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	Params    map[string]string `json:"params"`
+	Timeout   time.Duration     `json:"timeout"`
+	templates *Templates
+	client    *http.Client
+	logger    *log.Logger
+}
+
+func newWebhookActioner(meta ActionerMeta, templates *Templates, logger *log.Logger) *WebhookActioner {
+	wa := &WebhookActioner{
+		URL:       meta.Params["url"],
+		Method:    meta.Params["method"],
+		Headers:   meta.Headers,
+		Params:    meta.Params,
+		Timeout:   durationParam(meta.Params, "timeout", 10*time.Second, logger),
+		templates: templates,
+		logger:    logger,
+	}
+	if wa.Method == "" {
+		wa.Method = http.MethodPost
+	}
+	wa.client = &http.Client{Timeout: wa.Timeout}
+	return wa
+}
+
+// Action renders the body template over the check output and sends it. The url and body
+// (or body_template) can be overridden per action through its params.
+func (wa *WebhookActioner) Action(ctx context.Context, action string, payload *Payload, params map[string]string, workingDir string) (Output, error) {
+	out := Output{
+		RC:        -1,
+		Timestamp: time.Now().UTC(),
+	}
+	url := wa.URL
+	if params["url"] != "" {
+		url = params["url"]
+	}
+	if url == "" {
+		return out, fmt.Errorf("No url configured for webhook")
+	}
+	body, _, err := wa.templates.renderParam("body", defaultWebhookBody, newTemplateData(payload, params), params, wa.Params)
+	if err != nil {
+		return out, fmt.Errorf("Error rendering webhook body: %v", err)
+	}
+	status, resp, err := send(ctx, wa.client, wa.Method, url, wa.Headers, []byte(body))
+	out.RC = status
+	out.CombinedOut = string(resp)
+	if err != nil {
+		out.Error = err
+		return out, err
+	}
+	return out, nil
+}
+
+// send sends the body, returning the status code and response body. It makes a single attempt,
+// the dispatcher retrying actions as configured on them.
+func send(ctx context.Context, client *http.Client, method string, url string, headers map[string]string, body []byte) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return -1, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return -1, nil, err
+	}
+	defer res.Body.Close()
+	resp, _ := io.ReadAll(res.Body)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, resp, fmt.Errorf("Unexpected status code %d", res.StatusCode)
+	}
+	return res.StatusCode, resp, nil
+}
+
+func durationParam(params map[string]string, key string, def time.Duration, logger *log.Logger) time.Duration {
+	v, ok := params[key]
+	if !ok || v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		logger.Warn("Invalid duration param, using default", "param", key, "value", v, "default", def)
+		return def
+	}
+	return d
+}
@@ -0,0 +1,53 @@
+package actions_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/tchaudhry91/algomon/actions"
+)
+
+func TestWebhookAction(t *testing.T) {
+	attempts := 0
+	received := map[string]any{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		if r.Header.Get("X-Token") != "secret" {
+			t.Errorf("Missing configured header")
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Could not decode body:%v", err)
+		}
+	}))
+	defer srv.Close()
+
+	actioner := actions.Build(actions.ActionerMeta{
+		Type:    "webhook",
+		Params:  map[string]string{"url": srv.URL, "body": `{"check": {{ json .Check.Name }}, "title": {{ json .Result.title }}, "channel": {{ json .Params.channel }}}`},
+		Headers: map[string]string{"X-Token": "secret"},
+	}, nil, log.Default())
+	payload := testPayload(actions.StateFiring, "", `{"title": "Offset Threshold Violation"}`)
+	// Retries are left to the dispatcher
+	out, err := actioner.Action(context.Background(), "", payload, map[string]string{"channel": "ops"}, t.TempDir())
+	if err == nil || out.RC != http.StatusBadGateway || attempts != 1 {
+		t.Fatalf("Expected a single failed attempt, got rc %d after %d attempts: %v", out.RC, attempts, err)
+	}
+	out, err = actioner.Action(context.Background(), "", payload, map[string]string{"channel": "ops"}, t.TempDir())
+	if err != nil {
+		t.Fatalf("Webhook action failed:%v", err)
+	}
+	if out.RC != http.StatusOK || attempts != 2 {
+		t.Fatalf("Expected success on the second action, got rc %d after %d attempts", out.RC, attempts)
+	}
+	if received["check"] != "HTTP Check" || received["title"] != "Offset Threshold Violation" || received["channel"] != "ops" {
+		t.Fatalf("Unexpected body received: %v", received)
+	}
+}
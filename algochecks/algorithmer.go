@@ -11,21 +11,40 @@ import (
 
 var StatusFailed = "FAILED"
 var StatusSuccess = "SUCCESSFUL"
+var StatusSkipped = "SKIPPED"
+var StatusTimeout = "TIMEOUT"
 
 type Output struct {
-	Name        string    `json:"name"`
-	Status      string    `json:"status"`
-	Timestamp   time.Time `json:"timestamp"`
-	CombinedOut string    `json:"combined_out"`
-	RC          int       `json:"rc"`
-	Error       string    `json:"error"`
-	ActionKeys  []string  `json:"action_keys"`
+	Name        string           `json:"name"`
+	Status      string           `json:"status"`
+	Timestamp   time.Time        `json:"timestamp"`
+	CombinedOut string           `json:"combined_out"`
+	RC          int              `json:"rc"`
+	Error       string           `json:"error"`
+	ActionKeys  []string         `json:"action_keys"`
+	Ack         *Acknowledgement `json:"ack,omitempty"`
+	Alert       *AlertState      `json:"alert,omitempty"`
+	NextRun     *time.Time       `json:"next_run,omitempty"`
+}
+
+// Failing is true for runs that failed or timed out
+func (o *Output) Failing() bool {
+	return o.Status == StatusFailed || o.Status == StatusTimeout
+}
+
+// Acknowledgement marks a failing check as being worked on, suppressing its actions
+type Acknowledgement struct {
+	By        string    `json:"by"`
+	Comment   string    `json:"comment"`
+	Timestamp time.Time `json:"timestamp"`
 }
 
 type AlgorithmerMeta struct {
 	Type        string            `json:"type"`
 	Params      map[string]string `json:"params"`
 	EnvOverride map[string]string `json:"env_override"`
+	// MaxConcurrent bounds the number of checks of this algorithmer running at once, 0 being unbounded
+	MaxConcurrent int `json:"max_concurrent"`
 }
 
 type Algorithmer interface {
@@ -7,12 +7,51 @@ import (
 
 type Check struct {
 	Name            string                `json:"name"`
+	Labels          map[string]string     `json:"labels"`
 	Inputs          []measure.Measurement `json:"inputs"`
 	AlgorithmerType string                `json:"algorithmer_type"`
 	Algorithm       string                `json:"algorithm"`
 	AlgorithmParams map[string]string     `json:"algorithm_params"`
 	Actions         []actions.ActionMeta  `json:"actions"`
 	Interval        measure.Duration      `json:"interval"`
-	Immediate       bool                  `json:"immediate"`
-	Debug           bool                  `json:"debug"`
+	// Cron schedules the check at set times instead of on its interval
+	Cron        string          `json:"cron"`
+	Immediate   bool            `json:"immediate"`
+	Debug       bool            `json:"debug"`
+	ActiveTimes *ActiveSchedule `json:"active_times"`
+	// Escalation holds the actions back until the check has been firing for the delay of their step
+	Escalation []EscalationStep `json:"escalation"`
+	// DependsOn names the checks that must be healthy for this check to run
+	DependsOn []string `json:"depends_on"`
+	// Timeout bounds a run of the check, its actions included, overriding the global check_timeout
+	Timeout measure.Duration `json:"timeout"`
+	// Overlap is "skip" or "queue", deciding what happens to runs due while the previous one is still going
+	Overlap string `json:"overlap"`
+}
+
+// ActionPayload describes a run of the check to the actions dispatched for it
+func (c *Check) ActionPayload(state string, inputs map[string]measure.Result, output *Output, previousStatus string) *actions.Payload {
+	payload := &actions.Payload{
+		State:          state,
+		PreviousStatus: previousStatus,
+		Check: actions.CheckDefinition{
+			Name:            c.Name,
+			Labels:          c.Labels,
+			Inputs:          c.Inputs,
+			AlgorithmerType: c.AlgorithmerType,
+			Algorithm:       c.Algorithm,
+			AlgorithmParams: c.AlgorithmParams,
+			Interval:        c.Interval,
+		},
+		Inputs: inputs,
+		Output: actions.CheckOutput{
+			Status:      output.Status,
+			Timestamp:   output.Timestamp,
+			CombinedOut: output.CombinedOut,
+			RC:          output.RC,
+			Error:       output.Error,
+		},
+	}
+	payload.DecodeResult()
+	return payload
 }
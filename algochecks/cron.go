@@ -0,0 +1,14 @@
+package algochecks
+
+import (
+	"github.com/robfig/cron/v3"
+)
+
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// CronSchedule parses the cron expression of the check. Expressions have the standard five fields
+// or are descriptors like @hourly, and may be prefixed with CRON_TZ=<zone> to run in a timezone,
+// e.g. "CRON_TZ=Europe/Berlin 0 9 * * 1-5" for business days at 09:00 in Berlin.
+func (c *Check) CronSchedule() (cron.Schedule, error) {
+	return cronParser.Parse(c.Cron)
+}
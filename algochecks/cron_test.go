@@ -0,0 +1,27 @@
+package algochecks_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tchaudhry91/algomon/algochecks"
+)
+
+func TestCronSchedule(t *testing.T) {
+	c := &algochecks.Check{Name: "Batch Check", Cron: "CRON_TZ=Europe/Berlin 0 9 * * 1-5"}
+	schedule, err := c.CronSchedule()
+	if err != nil {
+		t.Fatalf("Cron schedule should be valid:%v", err)
+	}
+	// Friday evening in Berlin, next run is Monday 09:00 CET
+	from, _ := time.Parse(time.RFC3339, "2025-02-14T18:00:00Z")
+	next := schedule.Next(from)
+	if expected, _ := time.Parse(time.RFC3339, "2025-02-17T08:00:00Z"); !next.Equal(expected) {
+		t.Fatalf("Next run at %s, expected %s", next, expected)
+	}
+
+	c.Cron = "0 9 * *"
+	if _, err := c.CronSchedule(); err == nil {
+		t.Fatalf("Expected an error for a cron expression missing a field")
+	}
+}
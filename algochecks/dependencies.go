@@ -0,0 +1,51 @@
+package algochecks
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DependencyOrder orders the checks so that every check comes after the checks it depends on.
+// It fails on dependencies on undefined checks and on dependency cycles.
+func DependencyOrder(checks []Check) ([]*Check, error) {
+	byName := map[string]*Check{}
+	for i := range checks {
+		byName[checks[i].Name] = &checks[i]
+	}
+	ordered := make([]*Check, 0, len(checks))
+	// visiting holds the current dependency path, visited the checks already ordered
+	visiting := map[string]bool{}
+	visited := map[string]bool{}
+	path := []string{}
+	var visit func(c *Check) error
+	visit = func(c *Check) error {
+		if visited[c.Name] {
+			return nil
+		}
+		if visiting[c.Name] {
+			return fmt.Errorf("dependency cycle: %s -> %s", strings.Join(path, " -> "), c.Name)
+		}
+		visiting[c.Name] = true
+		path = append(path, c.Name)
+		for _, name := range c.DependsOn {
+			dep, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("check %q depends on undefined check %q", c.Name, name)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		visiting[c.Name] = false
+		visited[c.Name] = true
+		ordered = append(ordered, c)
+		return nil
+	}
+	for i := range checks {
+		if err := visit(&checks[i]); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
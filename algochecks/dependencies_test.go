@@ -0,0 +1,30 @@
+package algochecks_test
+
+import (
+	"testing"
+
+	"github.com/tchaudhry91/algomon/algochecks"
+)
+
+func TestDependencyOrder(t *testing.T) {
+	checks := []algochecks.Check{
+		{Name: "Latency SLO", DependsOn: []string{"Data Present"}},
+		{Name: "Data Present", DependsOn: []string{"Datasource Up"}},
+		{Name: "Datasource Up"},
+	}
+	ordered, err := algochecks.DependencyOrder(checks)
+	if err != nil {
+		t.Fatalf("Could not order checks:%v", err)
+	}
+	expected := []string{"Datasource Up", "Data Present", "Latency SLO"}
+	for i, c := range ordered {
+		if c.Name != expected[i] {
+			t.Fatalf("Unexpected order at %d: %s, expected %s", i, c.Name, expected[i])
+		}
+	}
+
+	checks[2].DependsOn = []string{"Latency SLO"}
+	if _, err := algochecks.DependencyOrder(checks); err == nil {
+		t.Fatalf("Expected an error for a dependency cycle")
+	}
+}
@@ -0,0 +1,85 @@
+package algochecks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tchaudhry91/algomon/actions"
+	"github.com/tchaudhry91/algomon/measure"
+)
+
+// EscalationStep notifies the named actions of a check once it has been firing, unacknowledged, for After
+type EscalationStep struct {
+	After   measure.Duration `json:"after"`
+	Actions []string         `json:"actions"`
+}
+
+// AlertState is the persisted state of a firing check, used to evaluate its escalation
+type AlertState struct {
+	FiringSince time.Time `json:"firing_since"`
+	// Step is the number of escalation steps reached so far
+	Step        int       `json:"step"`
+	EscalatedAt time.Time `json:"escalated_at,omitempty"`
+	// Silent is set until actions are first dispatched for the firing, so that a firing nobody was
+	// told about, e.g. as it was inhibited, is not resolved either
+	Silent bool `json:"silent,omitempty"`
+}
+
+// ValidateEscalation checks that the escalation steps reference actions of the check and are ordered by delay
+func (c *Check) ValidateEscalation() error {
+	names := map[string]struct{}{}
+	for _, a := range c.Actions {
+		names[a.Name] = struct{}{}
+	}
+	seen := map[string]struct{}{}
+	var previous time.Duration
+	for i, step := range c.Escalation {
+		if step.After.Duration < previous {
+			return fmt.Errorf("step %d is due before the step preceding it", i)
+		}
+		previous = step.After.Duration
+		if len(step.Actions) == 0 {
+			return fmt.Errorf("step %d has no actions", i)
+		}
+		for _, name := range step.Actions {
+			if _, ok := names[name]; !ok {
+				return fmt.Errorf("step %d references undefined action %q", i, name)
+			}
+			if _, ok := seen[name]; ok {
+				return fmt.Errorf("action %q is used by more than one step", name)
+			}
+			seen[name] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// EscalationStepsDue returns the number of escalation steps due after the check has been firing for the given duration
+func (c *Check) EscalationStepsDue(firing time.Duration) int {
+	due := 0
+	for _, step := range c.Escalation {
+		if step.After.Duration > firing {
+			break
+		}
+		due++
+	}
+	return due
+}
+
+// EscalatedActions returns the actions to notify once the given number of escalation steps is reached.
+// Actions not part of any step are always included.
+func (c *Check) EscalatedActions(steps int) []actions.ActionMeta {
+	stepOf := map[string]int{}
+	for i, step := range c.Escalation {
+		for _, name := range step.Actions {
+			stepOf[name] = i
+		}
+	}
+	selected := []actions.ActionMeta{}
+	for _, a := range c.Actions {
+		if i, ok := stepOf[a.Name]; !ok || i < steps {
+			selected = append(selected, a)
+		}
+	}
+	return selected
+}
@@ -0,0 +1,87 @@
+package algochecks_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tchaudhry91/algomon/actions"
+	"github.com/tchaudhry91/algomon/algochecks"
+	"github.com/tchaudhry91/algomon/measure"
+)
+
+func TestEscalation(t *testing.T) {
+	c := &algochecks.Check{
+		Name: "Latency SLO",
+		Actions: []actions.ActionMeta{
+			{Name: "log"},
+			{Name: "team-channel"},
+			{Name: "page-oncall"},
+		},
+		Escalation: []algochecks.EscalationStep{
+			{Actions: []string{"team-channel"}},
+			{After: measure.Duration{Duration: 15 * time.Minute}, Actions: []string{"page-oncall"}},
+		},
+	}
+	if err := c.ValidateEscalation(); err != nil {
+		t.Fatalf("Escalation should be valid:%v", err)
+	}
+	cases := []struct {
+		firing  time.Duration
+		due     int
+		actions []string
+	}{
+		{0, 1, []string{"log", "team-channel"}},
+		{14 * time.Minute, 1, []string{"log", "team-channel"}},
+		{15 * time.Minute, 2, []string{"log", "team-channel", "page-oncall"}},
+	}
+	for _, tc := range cases {
+		due := c.EscalationStepsDue(tc.firing)
+		if due != tc.due {
+			t.Errorf("EscalationStepsDue(%s) = %d, expected %d", tc.firing, due, tc.due)
+		}
+		names := []string{}
+		for _, a := range c.EscalatedActions(due) {
+			names = append(names, a.Name)
+		}
+		if len(names) != len(tc.actions) {
+			t.Errorf("Actions after %s = %v, expected %v", tc.firing, names, tc.actions)
+			continue
+		}
+		for i := range names {
+			if names[i] != tc.actions[i] {
+				t.Errorf("Actions after %s = %v, expected %v", tc.firing, names, tc.actions)
+				break
+			}
+		}
+	}
+
+}
+
+func TestValidateEscalation(t *testing.T) {
+	after := func(d time.Duration, names ...string) algochecks.EscalationStep {
+		return algochecks.EscalationStep{After: measure.Duration{Duration: d}, Actions: names}
+	}
+	cases := []struct {
+		name     string
+		steps    []algochecks.EscalationStep
+		expected string
+	}{
+		{"out of order", []algochecks.EscalationStep{after(15*time.Minute, "team-channel"), after(0, "page-oncall")}, "step 1 is due before the step preceding it"},
+		{"no actions", []algochecks.EscalationStep{after(0, "team-channel"), after(15 * time.Minute)}, "step 1 has no actions"},
+		{"undefined action", []algochecks.EscalationStep{after(0, "team-channel"), after(15*time.Minute, "missing")}, `step 1 references undefined action "missing"`},
+		{"action in two steps", []algochecks.EscalationStep{after(0, "team-channel"), after(15*time.Minute, "team-channel")}, `action "team-channel" is used by more than one step`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &algochecks.Check{
+				Name:       "Latency SLO",
+				Actions:    []actions.ActionMeta{{Name: "team-channel"}, {Name: "page-oncall"}},
+				Escalation: tc.steps,
+			}
+			err := c.ValidateEscalation()
+			if err == nil || err.Error() != tc.expected {
+				t.Fatalf("Expected error %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"syscall"
 	"time"
 
 	log "github.com/charmbracelet/log"
@@ -58,6 +59,12 @@ func (pa *PythonAlgorithmer) ApplyAlgorithm(ctx context.Context, algorithm strin
 	cmd.Dir = workingDir
 	cmd.Env = os.Environ()
 	cmd.Env = append(cmd.Env, envMapToSlice(pa.EnvOverride)...)
+	// Kill the whole process group on timeout, so the interpreter does not outlive the shell
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
 
 	combined, err := cmd.CombinedOutput()
 	if err != nil {
@@ -0,0 +1,159 @@
+package algochecks
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var SuppressExecution = "execution"
+var SuppressActions = "actions"
+
+// TimeWindow is a recurring range of time on a set of weekdays, e.g. mon-fri 09:00-17:00.
+// An End before Start wraps over midnight.
+type TimeWindow struct {
+	Weekdays []string `json:"weekdays"`
+	Start    string   `json:"start"`
+	End      string   `json:"end"`
+}
+
+// ActiveSchedule restricts a check to a set of windows. Outside of them either the check
+// is not executed at all, or it runs without dispatching actions.
+type ActiveSchedule struct {
+	Timezone string       `json:"timezone"`
+	Windows  []TimeWindow `json:"windows"`
+	Suppress string       `json:"suppress"`
+}
+
+// weekdays maps the short and full names of the days, matched case insensitively
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// Validate checks that the schedule can be evaluated
+func (s *ActiveSchedule) Validate() error {
+	if _, err := s.location(); err != nil {
+		return err
+	}
+	switch s.Suppress {
+	case "", SuppressExecution, SuppressActions:
+	default:
+		return fmt.Errorf("unknown suppress mode %q", s.Suppress)
+	}
+	if len(s.Windows) == 0 {
+		return fmt.Errorf("at least one window is required")
+	}
+	for _, w := range s.Windows {
+		if _, err := w.days(); err != nil {
+			return err
+		}
+		if _, err := parseClock(w.Start); err != nil {
+			return err
+		}
+		if _, err := parseClock(w.End); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SuppressMode returns what is suppressed outside of the windows, defaulting to execution
+func (s *ActiveSchedule) SuppressMode() string {
+	if s.Suppress == "" {
+		return SuppressExecution
+	}
+	return s.Suppress
+}
+
+// Active reports whether t falls within any of the windows of the schedule
+func (s *ActiveSchedule) Active(t time.Time) (bool, error) {
+	loc, err := s.location()
+	if err != nil {
+		return false, err
+	}
+	t = t.In(loc)
+	for _, w := range s.Windows {
+		ok, err := w.contains(t)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *ActiveSchedule) location() (*time.Location, error) {
+	if s.Timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %v", s.Timezone, err)
+	}
+	return loc, nil
+}
+
+func (w *TimeWindow) contains(t time.Time) (bool, error) {
+	days, err := w.days()
+	if err != nil {
+		return false, err
+	}
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return false, err
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return false, err
+	}
+	now := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if start <= end {
+		return days[t.Weekday()] && now >= start && now < end, nil
+	}
+	// The window wraps over midnight, the part after midnight belongs to the previous day
+	if now >= start {
+		return days[t.Weekday()], nil
+	}
+	if now < end {
+		return days[(t.Weekday()+6)%7], nil
+	}
+	return false, nil
+}
+
+func (w *TimeWindow) days() (map[time.Weekday]bool, error) {
+	days := map[time.Weekday]bool{}
+	if len(w.Weekdays) == 0 {
+		for _, d := range weekdays {
+			days[d] = true
+		}
+		return days, nil
+	}
+	for _, name := range w.Weekdays {
+		d, ok := weekdays[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("invalid weekday %q", name)
+		}
+		days[d] = true
+	}
+	return days, nil
+}
+
+// parseClock parses "HH:MM" into an offset from midnight, "24:00" is allowed as an end of day
+func parseClock(clock string) (time.Duration, error) {
+	if clock == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", clock)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
@@ -0,0 +1,67 @@
+package algochecks_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/tchaudhry91/algomon/algochecks"
+)
+
+func TestActiveSchedule(t *testing.T) {
+	s := &algochecks.ActiveSchedule{
+		Timezone: "UTC",
+		Windows: []algochecks.TimeWindow{
+			{Weekdays: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "09:00", End: "17:00"},
+			{Weekdays: []string{"sat"}, Start: "22:00", End: "02:00"},
+		},
+	}
+	if err := s.Validate(); err != nil {
+		t.Fatalf("Schedule should be valid:%v", err)
+	}
+	cases := []struct {
+		at     string
+		active bool
+	}{
+		{"2025-02-10T09:00:00Z", true},  // Monday
+		{"2025-02-10T17:00:00Z", false}, // Monday, end is exclusive
+		{"2025-02-09T12:00:00Z", false}, // Sunday
+		{"2025-02-15T23:00:00Z", true},  // Saturday night
+		{"2025-02-16T01:30:00Z", true},  // Saturday's window wrapping into Sunday
+		{"2025-02-17T01:30:00Z", false}, // Monday early morning
+	}
+	for _, c := range cases {
+		at, _ := time.Parse(time.RFC3339, c.at)
+		active, err := s.Active(at)
+		if err != nil {
+			t.Fatalf("Could not evaluate schedule:%v", err)
+		}
+		if active != c.active {
+			t.Errorf("Active(%s) = %v, expected %v", c.at, active, c.active)
+		}
+	}
+}
+
+func TestActiveScheduleInvalid(t *testing.T) {
+	s := &algochecks.ActiveSchedule{
+		Timezone: "Mars/Olympus",
+		Windows:  []algochecks.TimeWindow{{Start: "09:00", End: "17:00"}},
+	}
+	if err := s.Validate(); err == nil {
+		t.Fatalf("Expected invalid timezone to fail validation")
+	}
+	s = &algochecks.ActiveSchedule{Windows: []algochecks.TimeWindow{{Start: "9am", End: "17:00"}}}
+	if err := s.Validate(); err == nil {
+		t.Fatalf("Expected invalid start to fail validation")
+	}
+	for _, day := range []string{"ẞ", "monkey", "m", "mo", ""} {
+		s = &algochecks.ActiveSchedule{Windows: []algochecks.TimeWindow{{Weekdays: []string{day}, Start: "09:00", End: "17:00"}}}
+		if err := s.Validate(); err == nil || err.Error() != fmt.Sprintf("invalid weekday %q", day) {
+			t.Fatalf("Expected weekday %q to fail validation, got %v", day, err)
+		}
+	}
+	s = &algochecks.ActiveSchedule{Windows: []algochecks.TimeWindow{{Weekdays: []string{"Monday", "TUE", "sunday"}, Start: "09:00", End: "17:00"}}}
+	if err := s.Validate(); err != nil {
+		t.Fatalf("Expected short and full weekday names to be valid:%v", err)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/charmbracelet/log"
+	"github.com/tchaudhry91/algomon/actions"
+	"github.com/tchaudhry91/algomon/algochecks"
+	"github.com/tchaudhry91/algomon/store"
+)
+
+// Agent runs the checks of the config. On reload it swaps in the new config and only restarts
+// the checks that were added, changed or removed, leaving the schedules of the others running.
+type Agent struct {
+	configPath string
+	logger     *log.Logger
+	store      *store.BoltStore
+	dispatcher *Dispatcher
+	scheduler  *Scheduler
+
+	reloadMu     sync.Mutex
+	mu           sync.RWMutex
+	conf         *Config
+	algorithmers map[string]algochecks.Algorithmer
+	actioners    map[string]actions.Actioner
+	limiter      *ExecutionLimiter
+}
+
+// ReloadResult lists the checks by what a reload did to them
+type ReloadResult struct {
+	Added     []string `json:"added"`
+	Changed   []string `json:"changed"`
+	Removed   []string `json:"removed"`
+	Unchanged []string `json:"unchanged"`
+}
+
+// NewAgent builds the agent for a validated config, read from the file at configPath
+func NewAgent(configPath string, conf *Config, s *store.BoltStore, logger *log.Logger) (*Agent, error) {
+	a := &Agent{
+		configPath: configPath,
+		logger:     logger,
+		store:      s,
+		scheduler:  NewScheduler(logger.WithPrefix("scheduler")),
+	}
+	algorithmers, actioners, err := a.build(conf)
+	if err != nil {
+		return nil, err
+	}
+	a.conf = conf
+	a.algorithmers = algorithmers
+	a.actioners = actioners
+	a.limiter = NewExecutionLimiter(conf)
+	a.dispatcher = NewDispatcher(conf, s, actioners, logger.WithPrefix("dispatcher"))
+	return a, nil
+}
+
+// build creates the algorithmers and actioners of the config. Actioners whose definition did not
+// change are reused, so they keep their state across reloads.
+func (a *Agent) build(conf *Config) (map[string]algochecks.Algorithmer, map[string]actions.Actioner, error) {
+	algorithmers := make(map[string]algochecks.Algorithmer)
+	for _, aa := range conf.Algorithmers {
+		algorithmers[aa.Type] = algochecks.Build(aa, a.logger)
+	}
+
+	templates, err := actions.NewTemplates(conf.Templates, conf.ExternalURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Invalid templates: %v", err)
+	}
+	previous := map[string]actions.ActionerMeta{}
+	if a.conf != nil && reflect.DeepEqual(a.conf.Templates, conf.Templates) && a.conf.ExternalURL == conf.ExternalURL {
+		for _, aa := range a.conf.Actioners {
+			previous[aa.Type] = aa
+		}
+	}
+	actioners := make(map[string]actions.Actioner)
+	for _, aa := range conf.Actioners {
+		if old, ok := previous[aa.Type]; ok && reflect.DeepEqual(old, aa) {
+			actioners[aa.Type] = a.actioners[aa.Type]
+			continue
+		}
+		actioners[aa.Type] = actions.Build(aa, templates, a.logger)
+	}
+	return algorithmers, actioners, nil
+}
+
+// Config returns the config currently running
+func (a *Agent) Config() *Config {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.conf
+}
+
+func (a *Agent) state() (*Config, map[string]algochecks.Algorithmer, *ExecutionLimiter) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.conf, a.algorithmers, a.limiter
+}
+
+// Start schedules all checks, after the checks they depend on so dependencies run first
+func (a *Agent) Start() {
+	a.dispatcher.Start()
+	ordered, _ := algochecks.DependencyOrder(a.conf.Checks)
+	for _, c := range ordered {
+		a.schedule(a.conf, c)
+	}
+}
+
+func (a *Agent) schedule(conf *Config, c *algochecks.Check) {
+	a.logger.Info("Starting Check", "name", c.Name, "interval", c.Interval.Duration, "cron", c.Cron)
+	a.scheduler.Schedule(a.checkJob(conf, c))
+}
+
+// checkJob schedules runs of the check on its interval or cron schedule. Runs use the config
+// current at the time they start.
+func (a *Agent) checkJob(conf *Config, c *algochecks.Check) Job {
+	logger := a.logger.WithPrefix(c.Name)
+	next := everyInterval(c.Interval.Duration)
+	jitter := min(c.Interval.Duration/10, defaultMaxJitter)
+	if conf.ScheduleJitter != nil {
+		jitter = min(conf.ScheduleJitter.Duration, c.Interval.Duration)
+	}
+	if c.Cron != "" {
+		// Validated with the config, and cron checks run at their set times without jitter
+		schedule, _ := c.CronSchedule()
+		next = schedule.Next
+		jitter = 0
+	}
+	return Job{
+		Name:      c.Name,
+		Next:      next,
+		Jitter:    jitter,
+		Immediate: c.Immediate,
+		Overlap:   c.Overlap,
+		Run: func(ctx context.Context) {
+			run, withActions := activeState(c, time.Now())
+			if !run {
+				logger.Debug("Outside of active times, skipping execution")
+				return
+			}
+			conf, algorithmers, limiter := a.state()
+			release, err := limiter.Acquire(ctx, c.AlgorithmerType)
+			if err != nil {
+				logger.Warn("Cancelled while waiting for an execution slot", "err", err)
+				return
+			}
+			defer release()
+			if err := runCheck(ctx, c, conf, logger, a.store, algorithmers, a.dispatcher, withActions); err != nil {
+				logger.Error("err", err)
+			}
+		},
+	}
+}
+
+// Reload re-reads and validates the config file, then swaps it in. The running config is kept
+// if the new one is invalid.
+func (a *Agent) Reload() (*ReloadResult, error) {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	conf, err := loadConfig(a.configPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateConfig(conf, false); err != nil {
+		return nil, fmt.Errorf("Invalid configuration: %v", err)
+	}
+	applyRoutes(conf)
+	algorithmers, actioners, err := a.build(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	old := a.conf
+	a.conf = conf
+	a.algorithmers = algorithmers
+	a.actioners = actioners
+	// Runs hold on to the limiter they acquired a slot from, so a new one is only built when the
+	// limits change, the runs in progress not being counted against it
+	if !reflect.DeepEqual(executionLimits(old), executionLimits(conf)) {
+		a.limiter = NewExecutionLimiter(conf)
+	}
+	a.mu.Unlock()
+	a.dispatcher.Reload(conf, actioners)
+	if old.DatabaseFile != conf.DatabaseFile || old.APIListenAddr != conf.APIListenAddr ||
+		old.ActionWorkers != conf.ActionWorkers || old.ActionQueueSize != conf.ActionQueueSize {
+		a.logger.Warn("Changes to database_file, api_listen_addr, action_workers and action_queue_size need a restart")
+	}
+
+	result, updated := diffChecks(old, conf)
+	for _, c := range updated {
+		a.schedule(conf, c)
+	}
+	for _, name := range result.Removed {
+		a.logger.Info("Stopping Check", "name", name)
+		a.scheduler.Unschedule(name)
+	}
+	a.logger.Info("Reloaded config", "added", result.Added, "changed", result.Changed, "removed", result.Removed)
+	return result, nil
+}
+
+// diffChecks compares the checks of the configs by name, returning what a reload from old to conf
+// does to them along with the checks of conf that need to be scheduled, in dependency order
+func diffChecks(old *Config, conf *Config) (*ReloadResult, []*algochecks.Check) {
+	result := &ReloadResult{Added: []string{}, Changed: []string{}, Removed: []string{}, Unchanged: []string{}}
+	previous := map[string]*algochecks.Check{}
+	for i := range old.Checks {
+		previous[old.Checks[i].Name] = &old.Checks[i]
+	}
+	schedule := []*algochecks.Check{}
+	ordered, _ := algochecks.DependencyOrder(conf.Checks)
+	for _, c := range ordered {
+		prev, ok := previous[c.Name]
+		delete(previous, c.Name)
+		switch {
+		case !ok:
+			result.Added = append(result.Added, c.Name)
+		case !reflect.DeepEqual(prev, c):
+			result.Changed = append(result.Changed, c.Name)
+		default:
+			result.Unchanged = append(result.Unchanged, c.Name)
+			continue
+		}
+		schedule = append(schedule, c)
+	}
+	for name := range previous {
+		result.Removed = append(result.Removed, name)
+	}
+	sort.Strings(result.Removed)
+	return result, schedule
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/tchaudhry91/algomon/algochecks"
+	"github.com/tchaudhry91/algomon/measure"
+)
+
+func checkNames(checks []*algochecks.Check) []string {
+	names := []string{}
+	for _, c := range checks {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func TestDiffChecks(t *testing.T) {
+	check := func(name string, interval time.Duration, dependsOn ...string) algochecks.Check {
+		return algochecks.Check{Name: name, Interval: measure.Duration{Duration: interval}, DependsOn: dependsOn}
+	}
+	old := &Config{Checks: []algochecks.Check{check("Kept", time.Minute), check("Changed", time.Minute), check("Removed", time.Minute)}}
+	conf := &Config{Checks: []algochecks.Check{check("Added", time.Minute, "Changed"), check("Kept", time.Minute), check("Changed", time.Hour)}}
+
+	result, schedule := diffChecks(old, conf)
+	expected := &ReloadResult{Added: []string{"Added"}, Changed: []string{"Changed"}, Removed: []string{"Removed"}, Unchanged: []string{"Kept"}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, result)
+	}
+	// Checks are scheduled after the checks they depend on
+	if names := checkNames(schedule); !reflect.DeepEqual(names, []string{"Changed", "Added"}) {
+		t.Fatalf("Expected Changed then Added to be scheduled, got %v", names)
+	}
+}
+
+func writeTestConfig(t *testing.T, path string, conf map[string]any) {
+	data, err := json.Marshal(conf)
+	if err != nil {
+		t.Fatalf("Could not marshal config:%v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Could not write config:%v", err)
+	}
+}
+
+func testAgentConfig(dir string, maxConcurrent int, checks ...map[string]any) map[string]any {
+	return map[string]any{
+		"datasources":           []map[string]any{{"name": "prom", "url": "http://localhost:9090"}},
+		"algorithmers":          []map[string]any{{"type": "python", "params": map[string]string{"directory": dir}}},
+		"base_working_dir":      dir,
+		"max_concurrent_checks": maxConcurrent,
+		"checks":                checks,
+	}
+}
+
+func testAgentCheck(name string, interval string) map[string]any {
+	return map[string]any{
+		"name":             name,
+		"interval":         interval,
+		"algorithmer_type": "python",
+		"algorithm":        "algo",
+		"inputs":           []map[string]any{{"name": "up", "datasource": "prom", "query": "up"}},
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "algo.py"), nil, 0644); err != nil {
+		t.Fatalf("Could not write script:%v", err)
+	}
+	path := filepath.Join(dir, "algomon.json")
+	writeTestConfig(t, path, testAgentConfig(dir, 2, testAgentCheck("Kept", "1h"), testAgentCheck("Changed", "1h"), testAgentCheck("Removed", "1h")))
+	conf, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("Could not load config:%v", err)
+	}
+	applyRoutes(conf)
+	a, err := NewAgent(path, conf, newTestStore(t), log.Default())
+	if err != nil {
+		t.Fatalf("Could not create agent:%v", err)
+	}
+	a.Start()
+	defer a.scheduler.Stop(context.Background())
+	limiter := a.limiter
+
+	writeTestConfig(t, path, testAgentConfig(dir, 2, testAgentCheck("Kept", "1h"), testAgentCheck("Changed", "2h"), testAgentCheck("Added", "1h")))
+	result, err := a.Reload()
+	if err != nil {
+		t.Fatalf("Reload failed:%v", err)
+	}
+	expected := &ReloadResult{Added: []string{"Added"}, Changed: []string{"Changed"}, Removed: []string{"Removed"}, Unchanged: []string{"Kept"}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, result)
+	}
+	if _, ok := a.scheduler.NextRun("Removed"); ok {
+		t.Fatalf("Expected the removed check to be unscheduled")
+	}
+	waitFor(t, "the added check to be scheduled", func() bool { _, ok := a.scheduler.NextRun("Added"); return ok })
+	if a.limiter != limiter {
+		t.Fatalf("Expected the limiter to be kept when the limits did not change")
+	}
+
+	writeTestConfig(t, path, testAgentConfig(dir, 4, testAgentCheck("Kept", "1h")))
+	if _, err := a.Reload(); err != nil {
+		t.Fatalf("Reload failed:%v", err)
+	}
+	if a.limiter == limiter {
+		t.Fatalf("Expected a new limiter when the limits changed")
+	}
+
+	writeTestConfig(t, path, testAgentConfig(dir, 4, testAgentCheck("Kept", "0s")))
+	if _, err := a.Reload(); err == nil {
+		t.Fatalf("Expected the reload of an invalid config to fail")
+	}
+	if len(a.Config().Checks) != 1 || a.Config().Checks[0].Interval.Duration != time.Hour {
+		t.Fatalf("Expected the running config to be kept after a failed reload")
+	}
+}
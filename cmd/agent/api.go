@@ -5,20 +5,22 @@ import (
 	"errors"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/tchaudhry91/algomon/algochecks"
 	"github.com/tchaudhry91/algomon/store"
 )
 
 type APIServer struct {
 	e      *echo.Echo
 	db     *store.BoltStore
-	config *Config
+	agent  *Agent
 	logger *slog.Logger
 }
 
-func NewAPIServer(db *store.BoltStore, config *Config, logger *slog.Logger) *APIServer {
+func NewAPIServer(db *store.BoltStore, agent *Agent, logger *slog.Logger) *APIServer {
 	e := echo.New()
 	e.Use(middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
 		LogStatus:   true,
@@ -47,7 +49,7 @@ func NewAPIServer(db *store.BoltStore, config *Config, logger *slog.Logger) *API
 		e:      e,
 		db:     db,
 		logger: logger,
-		config: config,
+		agent:  agent,
 	}
 	server.Routes()
 	server.logger.Info("Registered Routes!")
@@ -62,16 +64,36 @@ func (s *APIServer) Routes() {
 	s.e.GET("/api/v1/checks", s.getChecksStatus)
 	s.e.GET("/api/v1/checks/:name", s.getNamedCheck)
 	s.e.GET("/api/v1/checks/:name/failures", s.getNamedCheckFailures)
+	s.e.POST("/api/v1/checks/:name/ack", s.ackNamedCheck)
+	s.e.DELETE("/api/v1/checks/:name/ack", s.unackNamedCheck)
+	s.e.GET("/api/v1/actions/failed", s.getFailedActions)
+	s.e.POST("/api/v1/actions/failed/:id/replay", s.replayFailedAction)
+	s.e.DELETE("/api/v1/actions/failed/:id", s.deleteFailedAction)
+	s.e.GET("/api/v1/actions/remediations", s.getRemediations)
+	s.e.POST("/api/v1/reload", s.reload)
 }
 
+// getChecksStatus returns the last output of every check, and the checks that did not run yet
+// with only their name and next run
 func (s *APIServer) getChecksStatus(c echo.Context) error {
 	data, err := s.db.GetChecksStatus(c.Request().Context())
-	if err != nil {
-		if errors.Is(err, store.ErrNotFound) {
-			return c.JSON(http.StatusOK, []string{})
-		}
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
 		return err
 	}
+	stored := map[string]struct{}{}
+	for _, out := range data {
+		stored[out.Name] = struct{}{}
+	}
+	for _, check := range s.agent.Config().Checks {
+		if _, ok := stored[check.Name]; !ok {
+			data = append(data, algochecks.Output{Name: check.Name})
+		}
+	}
+	for i := range data {
+		if next, ok := s.agent.scheduler.NextRun(data[i].Name); ok {
+			data[i].NextRun = &next
+		}
+	}
 	return c.JSON(http.StatusOK, data)
 }
 
@@ -98,3 +120,115 @@ func (s *APIServer) getNamedCheckFailures(c echo.Context) error {
 	}
 	return c.JSON(http.StatusOK, data)
 }
+
+type ackRequest struct {
+	By      string `json:"by"`
+	Comment string `json:"comment"`
+}
+
+func (s *APIServer) ackNamedCheck(c echo.Context) error {
+	name := c.Param("name")
+	if !s.checkDefined(name) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "check not found"})
+	}
+	req := ackRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+	}
+	if req.By == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "by is required"})
+	}
+	ack := algochecks.Acknowledgement{
+		By:        req.By,
+		Comment:   req.Comment,
+		Timestamp: time.Now().UTC(),
+	}
+	if err := s.db.PutAck(c.Request().Context(), name, &ack); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, ack)
+}
+
+func (s *APIServer) unackNamedCheck(c echo.Context) error {
+	name := c.Param("name")
+	if !s.checkDefined(name) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "check not found"})
+	}
+	if err := s.db.DeleteAck(c.Request().Context(), name); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (s *APIServer) checkDefined(name string) bool {
+	for _, c := range s.agent.Config().Checks {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *APIServer) getFailedActions(c echo.Context) error {
+	data, err := s.db.GetFailedActions(c.Request().Context())
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, data)
+}
+
+func (s *APIServer) replayFailedAction(c echo.Context) error {
+	id := c.Param("id")
+	out, err := s.agent.dispatcher.Replay(c.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "failed action not found"})
+		}
+		if errors.Is(err, ErrReplayInProgress) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		if errors.Is(err, ErrRemediationReplay) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusBadGateway, map[string]any{"error": err.Error(), "output": out})
+	}
+	return c.JSON(http.StatusOK, out)
+}
+
+func (s *APIServer) deleteFailedAction(c echo.Context) error {
+	id := c.Param("id")
+	if err := s.db.DeleteFailedAction(c.Request().Context(), id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "failed action not found"})
+		}
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// getRemediations returns the remediation audit log, optionally filtered by check and action
+// and limited to the given duration back (24h by default)
+func (s *APIServer) getRemediations(c echo.Context) error {
+	since := 24 * time.Hour
+	if raw := c.QueryParam("since"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid since duration"})
+		}
+		since = d
+	}
+	data, err := s.db.GetRemediations(c.Request().Context(), c.QueryParam("check"), c.QueryParam("action"), time.Now().Add(-since))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, data)
+}
+
+// reload re-reads the config file, restarting only the checks that were added, changed or removed
+func (s *APIServer) reload(c echo.Context) error {
+	result, err := s.agent.Reload()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/tchaudhry91/algomon/algochecks"
+	"github.com/tchaudhry91/algomon/measure"
+)
+
+func newTestAPI(t *testing.T, conf *Config) (*APIServer, *Agent) {
+	conf.BaseWorkingDir = t.TempDir()
+	s := newTestStore(t)
+	a, err := NewAgent("", conf, s, log.Default())
+	if err != nil {
+		t.Fatalf("Could not create agent:%v", err)
+	}
+	a.Start()
+	t.Cleanup(func() {
+		a.scheduler.Stop(context.Background())
+		a.dispatcher.Shutdown(context.Background())
+	})
+	return NewAPIServer(s, a, slog.New(slog.NewTextHandler(io.Discard, nil))), a
+}
+
+// apiRequest serves the request, decoding the JSON response into out unless it is nil
+func apiRequest(t *testing.T, api *APIServer, method string, path string, body string, out any) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	api.Mux().ServeHTTP(rec, req)
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("Could not decode response %q:%v", rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+func TestGetChecksStatusListsChecksNotRunYet(t *testing.T) {
+	conf := &Config{
+		Algorithmers: []algochecks.AlgorithmerMeta{{Type: "python"}},
+		Checks: []algochecks.Check{
+			{Name: "Ran", AlgorithmerType: "python", Interval: measure.Duration{Duration: time.Hour}},
+			{Name: "Business Days", AlgorithmerType: "python", Cron: "0 9 * * 1-5"},
+		},
+	}
+	api, a := newTestAPI(t, conf)
+	a.store.PutCheck(context.Background(), &conf.Checks[0], &algochecks.Output{Name: "Ran", Status: algochecks.StatusSuccess, Timestamp: time.Now().UTC()})
+	waitFor(t, "the checks to be scheduled", func() bool {
+		_, ran := a.scheduler.NextRun("Ran")
+		_, cron := a.scheduler.NextRun("Business Days")
+		return ran && cron
+	})
+
+	statuses := []algochecks.Output{}
+	if code := apiRequest(t, api, http.MethodGet, "/api/v1/checks", "", &statuses); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("Expected both checks to be listed, got %+v", statuses)
+	}
+	for _, status := range statuses {
+		if status.NextRun == nil {
+			t.Fatalf("Expected check %q to have a next run", status.Name)
+		}
+	}
+	if statuses[0].Name != "Ran" || statuses[0].Status != algochecks.StatusSuccess {
+		t.Fatalf("Expected the stored output of Ran, got %+v", statuses[0])
+	}
+	if statuses[1].Name != "Business Days" || statuses[1].Status != "" || statuses[1].NextRun.Hour() != 9 {
+		t.Fatalf("Expected the cron check to be listed with its next run at 9, got %+v", statuses[1])
+	}
+}
+
+func TestAckAPI(t *testing.T) {
+	conf := &Config{
+		Algorithmers: []algochecks.AlgorithmerMeta{{Type: "python"}},
+		Checks:       []algochecks.Check{{Name: "API", AlgorithmerType: "python", Interval: measure.Duration{Duration: time.Hour}}},
+	}
+	api, a := newTestAPI(t, conf)
+	a.store.PutCheck(context.Background(), &conf.Checks[0], &algochecks.Output{Name: "API", Status: algochecks.StatusFailed, RC: 1, Timestamp: time.Now().UTC()})
+
+	if code := apiRequest(t, api, http.MethodPost, "/api/v1/checks/DB/ack", `{"by": "oncall"}`, nil); code != http.StatusNotFound {
+		t.Fatalf("Expected 404 acknowledging an unknown check, got %d", code)
+	}
+	if code := apiRequest(t, api, http.MethodPost, "/api/v1/checks/API/ack", `{"comment": "looking"}`, nil); code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 acknowledging without by, got %d", code)
+	}
+	ack := algochecks.Acknowledgement{}
+	if code := apiRequest(t, api, http.MethodPost, "/api/v1/checks/API/ack", `{"by": "oncall", "comment": "looking"}`, &ack); code != http.StatusOK {
+		t.Fatalf("Expected 200 acknowledging, got %d", code)
+	}
+	if ack.By != "oncall" || ack.Comment != "looking" || ack.Timestamp.IsZero() {
+		t.Fatalf("Unexpected acknowledgement %+v", ack)
+	}
+
+	statuses := []algochecks.Output{}
+	apiRequest(t, api, http.MethodGet, "/api/v1/checks", "", &statuses)
+	if len(statuses) != 1 || statuses[0].Ack == nil || statuses[0].Ack.By != "oncall" {
+		t.Fatalf("Expected the status to carry the acknowledgement, got %+v", statuses)
+	}
+
+	if code := apiRequest(t, api, http.MethodDelete, "/api/v1/checks/DB/ack", "", nil); code != http.StatusNotFound {
+		t.Fatalf("Expected 404 unacknowledging an unknown check, got %d", code)
+	}
+	if code := apiRequest(t, api, http.MethodDelete, "/api/v1/checks/API/ack", "", nil); code != http.StatusNoContent {
+		t.Fatalf("Expected 204 unacknowledging, got %d", code)
+	}
+	statuses = []algochecks.Output{}
+	apiRequest(t, api, http.MethodGet, "/api/v1/checks", "", &statuses)
+	if len(statuses) != 1 || statuses[0].Ack != nil {
+		t.Fatalf("Expected the acknowledgement to be removed, got %+v", statuses[0].Ack)
+	}
+}
@@ -1,8 +1,23 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/tchaudhry91/algomon/actions"
 	"github.com/tchaudhry91/algomon/algochecks"
+	"github.com/tchaudhry91/algomon/measure"
+	"gopkg.in/yaml.v3"
 )
 
 type Datasource struct {
@@ -18,6 +33,346 @@ type Config struct {
 	BaseWorkingDir string                       `json:"base_working_dir"`
 	DatabaseFile   string                       `json:"database_file"`
 	APIListenAddr  string                       `json:"api_listen_addr"`
+	ExternalURL    string                       `json:"external_url"`
+	Templates      map[string]string            `json:"templates"`
+	// FailedActionRetryInterval is how often actions that failed all their retries are retried
+	FailedActionRetryInterval measure.Duration `json:"failed_action_retry_interval"`
+	// FailedActionMaxAge and FailedActionMaxCount bound the failed action queue, the oldest entries
+	// being dropped first. They default to 24h and 1000.
+	FailedActionMaxAge   measure.Duration `json:"failed_action_max_age"`
+	FailedActionMaxCount int              `json:"failed_action_max_count"`
+	ActionWorkers        int              `json:"action_workers"`
+	ActionQueueSize      int              `json:"action_queue_size"`
+	// ActionDrainTimeout bounds how long queued actions are given to complete on shutdown
+	ActionDrainTimeout measure.Duration `json:"action_drain_timeout"`
+	// Receivers and Routes give checks that define no actions of their own the actions of their team
+	Receivers []Receiver `json:"receivers"`
+	Routes    []Route    `json:"routes"`
+	// CheckTimeout bounds the runs of checks that do not set their own timeout, 5m by default
+	CheckTimeout measure.Duration `json:"check_timeout"`
+	// MaxConcurrentChecks bounds the number of checks running at once, 0 being unbounded
+	MaxConcurrentChecks int `json:"max_concurrent_checks"`
+	// ScheduleJitter is the maximum random delay added to the schedule of each check, so they do not all
+	// run at once. It defaults to a tenth of the check interval, up to 10s.
+	ScheduleJitter *measure.Duration `json:"schedule_jitter"`
+	// InhibitRules suppress the actions of checks while the checks they depend on are failing
+	InhibitRules []InhibitRule `json:"inhibit_rules"`
+}
+
+// Receiver is a named list of actions that routes resolve to
+type Receiver struct {
+	Name    string               `json:"name"`
+	Actions []actions.ActionMeta `json:"actions"`
+}
+
+// Route sends the checks whose labels match all of Match to a receiver. Routes are evaluated in
+// order and the first matching route wins, unless it is set to continue to the following ones.
+// A route without Match matches every check.
+type Route struct {
+	Match    map[string]string `json:"match"`
+	Receiver string            `json:"receiver"`
+	Continue bool              `json:"continue"`
+}
+
+func (r *Route) matches(labels map[string]string) bool {
+	for k, v := range r.Match {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// loadConfig reads the config at path, which is a file, a directory or a glob. The files of a
+// directory or glob are merged in lexical order, see mergeConfig. Unknown fields and conflicting
+// definitions are all reported in the returned error, along with the config merged without them.
+func loadConfig(path string) (*Config, error) {
+	files, err := configFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	confs := make([]*Config, len(files))
+	errs := []error{}
+	for i, file := range files {
+		conf, unknown, err := readConfigFile(file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		confs[i] = conf
+		for _, field := range unknown {
+			errs = append(errs, fmt.Errorf("%s: unknown field %s", file, field))
+		}
+	}
+	if len(errs) > 0 && slices.Contains(confs, nil) {
+		return nil, errors.Join(errs...)
+	}
+	merged := &Config{}
+	origins := map[string]string{}
+	for i, file := range files {
+		if err := mergeConfig(merged, confs[i], file, origins); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return merged, errors.Join(errs...)
+}
+
+// configFiles lists the config files at path
+func configFiles(path string) ([]string, error) {
+	pattern := path
+	if info, err := os.Stat(path); err == nil {
+		if !info.IsDir() {
+			return []string{path}, nil
+		}
+		files := []string{}
+		for _, ext := range configExtensions {
+			matches, _ := filepath.Glob(filepath.Join(path, "*"+ext))
+			files = append(files, matches...)
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("No config files in %s", path)
+		}
+		sort.Strings(files)
+		return files, nil
+	} else if !strings.ContainsAny(path, "*?[") {
+		return nil, fmt.Errorf("Error Reading Config File: %v", err)
+	}
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid config glob %q: %v", pattern, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("No config files match %q", pattern)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+var configExtensions = []string{".json", ".yaml", ".yml"}
+
+// readConfigFile reads a JSON or YAML config file, expanding the references in its strings. YAML is
+// converted to JSON first, so both formats are unmarshalled the same way. It also returns the paths
+// of the fields of the file that are not part of the config.
+func readConfigFile(file string) (*Config, []string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error Reading Config File: %v", err)
+	}
+	var raw any
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&raw)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to Parse Config %s: %v", file, err)
+	}
+	raw, err = expandConfigValues(raw, filepath.Dir(file))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to Expand Config %s: %v", file, err)
+	}
+	raw = coerceStrings(raw, reflect.TypeOf(Config{}))
+	data, err = json.Marshal(raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to Convert Config %s: %v", file, err)
+	}
+	conf := &Config{}
+	if err := json.Unmarshal(data, conf); err != nil {
+		return nil, nil, fmt.Errorf("Unable to Unmarshal Config %s: %v", file, err)
+	}
+	return conf, unknownFields(raw, reflect.TypeOf(conf).Elem(), ""), nil
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// unknownFields lists the paths of the fields of raw that t has no field for. Fields are matched
+// to their JSON names without regard to case, the way encoding/json does.
+func unknownFields(raw any, t reflect.Type, path string) []string {
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return unknownFields(raw, t.Elem(), path)
+	case reflect.Slice:
+		list, _ := raw.([]any)
+		unknown := []string{}
+		for i, v := range list {
+			unknown = append(unknown, unknownFields(v, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return unknown
+	case reflect.Map:
+		m, _ := raw.(map[string]any)
+		unknown := []string{}
+		for k, v := range m {
+			unknown = append(unknown, unknownFields(v, t.Elem(), fieldPath(path, k))...)
+		}
+		sort.Strings(unknown)
+		return unknown
+	case reflect.Struct:
+		m, _ := raw.(map[string]any)
+		fields := jsonFields(t)
+		unknown := []string{}
+		for k, v := range m {
+			field, ok := fields[strings.ToLower(k)]
+			if !ok {
+				unknown = append(unknown, fieldPath(path, k))
+				continue
+			}
+			unknown = append(unknown, unknownFields(v, field, fieldPath(path, k))...)
+		}
+		sort.Strings(unknown)
+		return unknown
+	}
+	return nil
+}
+
+// coerceStrings turns the numbers and booleans of raw that t expects strings for into strings, so
+// unquoted YAML values like port: 587 in params are read as they were written
+func coerceStrings(raw any, t reflect.Type) any {
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		return raw
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return coerceStrings(raw, t.Elem())
+	case reflect.String:
+		switch v := raw.(type) {
+		case json.Number:
+			return v.String()
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		case int, int64, uint64, bool:
+			return fmt.Sprint(v)
+		}
+	case reflect.Slice:
+		if list, ok := raw.([]any); ok {
+			for i, v := range list {
+				list[i] = coerceStrings(v, t.Elem())
+			}
+		}
+	case reflect.Map:
+		if m, ok := raw.(map[string]any); ok {
+			for k, v := range m {
+				m[k] = coerceStrings(v, t.Elem())
+			}
+		}
+	case reflect.Struct:
+		if m, ok := raw.(map[string]any); ok {
+			fields := jsonFields(t)
+			for k, v := range m {
+				if field, ok := fields[strings.ToLower(k)]; ok {
+					m[k] = coerceStrings(v, field)
+				}
+			}
+		}
+	}
+	return raw
+}
+
+// jsonFields maps the lowercased JSON names of the fields of t, including those of embedded
+// structs, to their types
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for k, v := range jsonFields(f.Type) {
+				fields[k] = v
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[strings.ToLower(name)] = f.Type
+	}
+	return fields
+}
+
+func fieldPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// mergeConfig merges the config read from file into merged. Lists are appended to, with entries
+// identified by their name or type only defined once, and map entries and settings can only be set
+// by one file. origins records the file everything was first defined in, to name it in errors.
+// Conflicting definitions are all reported and left out of merged.
+func mergeConfig(merged *Config, conf *Config, file string, origins map[string]string) error {
+	errs := []error{}
+	dst := reflect.ValueOf(merged).Elem()
+	src := reflect.ValueOf(conf).Elem()
+	for i := 0; i < src.NumField(); i++ {
+		name := strings.Split(src.Type().Field(i).Tag.Get("json"), ",")[0]
+		sv, dv := src.Field(i), dst.Field(i)
+		if sv.IsZero() {
+			continue
+		}
+		switch sv.Kind() {
+		case reflect.Slice:
+			for j := 0; j < sv.Len(); j++ {
+				if id := configEntryID(sv.Index(j)); id != "" {
+					if err := claimConfigKey(origins, fmt.Sprintf("%s entry %q", name, id), file); err != nil {
+						errs = append(errs, err)
+						continue
+					}
+				}
+				dv.Set(reflect.Append(dv, sv.Index(j)))
+			}
+		case reflect.Map:
+			if dv.IsNil() {
+				dv.Set(reflect.MakeMap(sv.Type()))
+			}
+			for _, k := range sv.MapKeys() {
+				if err := claimConfigKey(origins, fmt.Sprintf("%s entry %q", name, k), file); err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				dv.SetMapIndex(k, sv.MapIndex(k))
+			}
+		default:
+			if err := claimConfigKey(origins, name, file); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			dv.Set(sv)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// configEntryID identifies an entry of a config list by its Name or Type field, if it has one
+func configEntryID(entry reflect.Value) string {
+	for _, field := range []string{"Name", "Type"} {
+		if f := entry.FieldByName(field); f.IsValid() && f.Kind() == reflect.String {
+			return f.String()
+		}
+	}
+	return ""
+}
+
+func claimConfigKey(origins map[string]string, key string, file string) error {
+	if previous, ok := origins[key]; ok {
+		if previous == file {
+			return fmt.Errorf("%s: %s is defined more than once", file, key)
+		}
+		return fmt.Errorf("%s: %s is already defined in %s", file, key, previous)
+	}
+	origins[key] = file
+	return nil
 }
 
 func fetchDatasourceByName(c *Config, name string) *Datasource {
@@ -28,3 +383,61 @@ func fetchDatasourceByName(c *Config, name string) *Datasource {
 	}
 	return nil
 }
+
+var defaultCheckTimeout = 5 * time.Minute
+
+// checkTimeout returns the timeout of a run of the check
+func checkTimeout(c *Config, check *algochecks.Check) time.Duration {
+	if check.Timeout.Duration > 0 {
+		return check.Timeout.Duration
+	}
+	if c.CheckTimeout.Duration > 0 {
+		return c.CheckTimeout.Duration
+	}
+	return defaultCheckTimeout
+}
+
+func fetchReceiverByName(c *Config, name string) *Receiver {
+	for _, r := range c.Receivers {
+		if r.Name == name {
+			return &r
+		}
+	}
+	return nil
+}
+
+// routedActions returns the actions of the check, or the actions of the receivers its labels
+// route to if it defines none of its own
+func routedActions(c *Config, check *algochecks.Check) []actions.ActionMeta {
+	if len(check.Actions) > 0 {
+		return check.Actions
+	}
+	routed := []actions.ActionMeta{}
+	seen := map[string]struct{}{}
+	for _, route := range c.Routes {
+		if !route.matches(check.Labels) {
+			continue
+		}
+		if receiver := fetchReceiverByName(c, route.Receiver); receiver != nil {
+			for _, a := range receiver.Actions {
+				// Action names key the stored outputs, so the first receiver using a name wins
+				if _, ok := seen[a.Name]; ok {
+					continue
+				}
+				seen[a.Name] = struct{}{}
+				routed = append(routed, a)
+			}
+		}
+		if !route.Continue {
+			break
+		}
+	}
+	return routed
+}
+
+// applyRoutes fills in the actions of the checks that define none from the routing tree
+func applyRoutes(c *Config) {
+	for i := range c.Checks {
+		c.Checks[i].Actions = routedActions(c, &c.Checks[i])
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tchaudhry91/algomon/actions"
+	"github.com/tchaudhry91/algomon/algochecks"
+)
+
+func writeFile(t *testing.T, path string, data string) {
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Could not write %s:%v", path, err)
+	}
+}
+
+func TestUnknownFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		data     string
+		expected []string
+	}{
+		{"known", "algomon.json", `{"checks": [{"name": "API", "Interval": "1m", "labels": {"any": "thing"}, "actions": [{"name": "a", "group": {"by": ["check"], "wait": "1s"}}]}], "schedule_jitter": "1s"}`, []string{}},
+		{"top level", "algomon.json", `{"bogus": true, "database_file": "algomon.db"}`, []string{"bogus"}},
+		{"nested", "algomon.json", `{"checks": [{"name": "API"}, {"name": "DB", "inputs": [{"name": "up", "qeury": "up"}], "actions": [{"name": "a", "remediation": {"dryrun": true}}]}]}`, []string{"checks[1].actions[0].remediation.dryrun", "checks[1].inputs[0].qeury"}},
+		{"yaml", "algomon.yaml", "datasources:\n  - name: prom\n    url: http://localhost\n    timeout: 1s\n", []string{"datasources[0].timeout"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			writeFile(t, path, tt.data)
+			_, unknown, err := readConfigFile(path)
+			if err != nil {
+				t.Fatalf("Could not read config:%v", err)
+			}
+			if !reflect.DeepEqual(unknown, tt.expected) {
+				t.Fatalf("Expected unknown fields %v, got %v", tt.expected, unknown)
+			}
+		})
+	}
+}
+
+func TestLoadConfigReportsUnknownFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "algomon.json")
+	writeFile(t, path, `{"database_file": "algomon.db", "checks": [{"name": "API", "intreval": "1m"}]}`)
+	conf, err := loadConfig(path)
+	if err == nil || err.Error() != path+": unknown field checks[0].intreval" {
+		t.Fatalf("Expected the unknown field to be reported, got %v", err)
+	}
+	if conf == nil || conf.DatabaseFile != "algomon.db" {
+		t.Fatalf("Expected the config to be returned along with the unknown fields")
+	}
+}
+
+func TestValidateCommand(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "valid.json"), `{"datasources": [{"name": "prom", "url": "http://localhost:9090"}]}`)
+	writeFile(t, filepath.Join(dir, "invalid.json"), `{"datasources": [{"name": "prom", "url": "localhost"}], "bogus": 1}`)
+	if code := validateCommand([]string{"-c", filepath.Join(dir, "valid.json")}); code != 0 {
+		t.Fatalf("Expected a valid config to exit with 0, got %d", code)
+	}
+	if code := validateCommand([]string{"-c", filepath.Join(dir, "invalid.json")}); code != 1 {
+		t.Fatalf("Expected an invalid config to exit with 1, got %d", code)
+	}
+}
+
+func TestLoadConfigMerges(t *testing.T) {
+	datasources := `{"datasources": [{"name": "prom", "url": "http://localhost:9090"}], "database_file": "algomon.db"}`
+	checks := "checks:\n  - name: API\n  - name: DB\n"
+	tests := []struct {
+		name     string
+		files    map[string]string
+		path     string
+		checks   int
+		expected string
+	}{
+		{"directory", map[string]string{"10-datasources.json": datasources, "20-checks.yaml": checks, "notes.txt": "ignored"}, "", 2, ""},
+		{"glob", map[string]string{"10-datasources.json": datasources, "20-checks.yaml": checks, "30-more.yaml": "checks:\n  - name: Web\n"}, "[12]0-*", 2, ""},
+		{"duplicate datasource", map[string]string{"10-datasources.json": datasources, "20-more.json": `{"datasources": [{"name": "prom", "url": "http://thanos"}]}`}, "",
+			0, `20-more.json: datasources entry "prom" is already defined in `},
+		{"duplicate check", map[string]string{"10-checks.yaml": checks, "20-checks.yaml": "checks:\n  - name: DB\n"}, "",
+			2, `20-checks.yaml: checks entry "DB" is already defined in `},
+		{"duplicate check in a file", map[string]string{"10-checks.yaml": "checks:\n  - name: DB\n  - name: DB\n"}, "",
+			1, `10-checks.yaml: checks entry "DB" is defined more than once`},
+		{"duplicate setting", map[string]string{"10-datasources.json": datasources, "20-db.json": `{"database_file": "other.db"}`}, "",
+			0, `20-db.json: database_file is already defined in `},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, data := range tt.files {
+				writeFile(t, filepath.Join(dir, name), data)
+			}
+			conf, err := loadConfig(filepath.Join(dir, tt.path))
+			if tt.expected == "" {
+				if err != nil {
+					t.Fatalf("Could not load config:%v", err)
+				}
+				if len(conf.Datasources) != 1 || conf.DatabaseFile != "algomon.db" {
+					t.Fatalf("Expected the datasources file to be merged, got %+v", conf)
+				}
+			} else if err == nil || !strings.HasPrefix(err.Error(), filepath.Join(dir, tt.expected)) {
+				t.Fatalf("Expected error %q naming the file, got %v", tt.expected, err)
+			}
+			if len(conf.Checks) != tt.checks {
+				t.Fatalf("Expected %d checks, got %d", tt.checks, len(conf.Checks))
+			}
+		})
+	}
+}
+
+func TestConfigFilesErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "notes.txt"), "")
+	for _, path := range []string{dir, filepath.Join(dir, "*.json"), filepath.Join(dir, "missing.json")} {
+		if _, err := configFiles(path); err == nil {
+			t.Fatalf("Expected no config files to be found at %s", path)
+		}
+	}
+}
+
+func actionNames(list []actions.ActionMeta) string {
+	names := []string{}
+	for _, a := range list {
+		names = append(names, a.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestRoutedActions(t *testing.T) {
+	receivers := []Receiver{
+		{Name: "oncall", Actions: []actions.ActionMeta{{Name: "Page"}, {Name: "Chat"}}},
+		{Name: "team", Actions: []actions.ActionMeta{{Name: "Chat"}, {Name: "Mail"}}},
+		{Name: "default", Actions: []actions.ActionMeta{{Name: "Log"}}},
+	}
+	tests := []struct {
+		name     string
+		routes   []Route
+		labels   map[string]string
+		own      []actions.ActionMeta
+		expected string
+	}{
+		{"no routes", nil, map[string]string{"severity": "critical"}, nil, ""},
+		{"all labels must match", []Route{
+			{Match: map[string]string{"severity": "critical", "team": "db"}, Receiver: "oncall"},
+		}, map[string]string{"severity": "critical"}, nil, ""},
+		{"first match wins", []Route{
+			{Match: map[string]string{"severity": "critical"}, Receiver: "oncall"},
+			{Match: map[string]string{"team": "db"}, Receiver: "team"},
+		}, map[string]string{"severity": "critical", "team": "db"}, nil, "Page,Chat"},
+		{"unmatched routes are skipped", []Route{
+			{Match: map[string]string{"severity": "critical"}, Receiver: "oncall"},
+			{Match: map[string]string{"team": "db"}, Receiver: "team"},
+		}, map[string]string{"severity": "warning", "team": "db"}, nil, "Chat,Mail"},
+		{"empty match catches all", []Route{
+			{Match: map[string]string{"severity": "critical"}, Receiver: "oncall"},
+			{Receiver: "default"},
+		}, map[string]string{"severity": "warning"}, nil, "Log"},
+		{"continue adds the following matches", []Route{
+			{Match: map[string]string{"severity": "critical"}, Receiver: "oncall", Continue: true},
+			{Match: map[string]string{"team": "api"}, Receiver: "team"},
+			{Receiver: "default"},
+		}, map[string]string{"severity": "critical"}, nil, "Page,Chat,Log"},
+		{"continue keeps the first action of a name", []Route{
+			{Match: map[string]string{"severity": "critical"}, Receiver: "oncall", Continue: true},
+			{Receiver: "team", Continue: true},
+			{Receiver: "default"},
+		}, map[string]string{"severity": "critical"}, nil, "Page,Chat,Mail,Log"},
+		{"undefined receiver", []Route{
+			{Receiver: "missing", Continue: true},
+			{Receiver: "default"},
+		}, nil, nil, "Log"},
+		{"own actions override the routes", []Route{
+			{Receiver: "oncall", Continue: true},
+			{Receiver: "default"},
+		}, map[string]string{"severity": "critical"}, []actions.ActionMeta{{Name: "Own"}}, "Own"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := &Config{Receivers: receivers, Routes: tt.routes}
+			check := &algochecks.Check{Name: "API", Labels: tt.labels, Actions: tt.own}
+			if got := actionNames(routedActions(conf, check)); got != tt.expected {
+				t.Fatalf("Expected actions %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestApplyRoutes(t *testing.T) {
+	conf := &Config{
+		Receivers: []Receiver{{Name: "default", Actions: []actions.ActionMeta{{Name: "Log"}}}},
+		Routes:    []Route{{Receiver: "default"}},
+		Checks: []algochecks.Check{
+			{Name: "Routed"},
+			{Name: "Own", Actions: []actions.ActionMeta{{Name: "Page"}}},
+		},
+	}
+	applyRoutes(conf)
+	if got := actionNames(conf.Checks[0].Actions); got != "Log" {
+		t.Fatalf("Expected the routed check to get the receiver actions, got %q", got)
+	}
+	if got := actionNames(conf.Checks[1].Actions); got != "Page" {
+		t.Fatalf("Expected the check to keep its own actions, got %q", got)
+	}
+}
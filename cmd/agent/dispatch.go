@@ -0,0 +1,484 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/charmbracelet/log"
+	"github.com/tchaudhry91/algomon/actions"
+	"github.com/tchaudhry91/algomon/algochecks"
+	"github.com/tchaudhry91/algomon/store"
+)
+
+var defaultRetryBackoff = time.Second
+var defaultFailedRetryInterval = 5 * time.Minute
+var defaultFailedMaxAge = 24 * time.Hour
+var defaultFailedMaxCount = 1000
+
+// ErrReplayInProgress is returned when a failed action is already being replayed
+var ErrReplayInProgress = errors.New("replay already in progress")
+
+// ErrRemediationReplay is returned when replaying a remediation, which only run when decided on
+var ErrRemediationReplay = errors.New("remediations are not replayed")
+var defaultActionWorkers = 4
+var defaultActionQueueSize = 100
+
+// actionJob is a single action to be delivered for a check run
+type actionJob struct {
+	checkName string
+	debug     bool
+	timeout   time.Duration
+	key       string
+	action    actions.ActionMeta
+	payload   *actions.Payload
+	// members and memberKeys are set on grouped notifications, to the checks notified together
+	// and the keys their outputs are stored under
+	members    []string
+	memberKeys []string
+}
+
+// Dispatcher delivers actions from a bounded queue on its own pool of workers, so check runs are
+// never blocked by notification latency. Actions are retried with backoff and the ones that keep
+// failing are persisted so they can be retried later or replayed through the API. Remediation
+// actions are guarded by their policy instead and are never persisted for a later run.
+type Dispatcher struct {
+	conf      *Config
+	store     *store.BoltStore
+	actioners map[string]actions.Actioner
+	logger    *log.Logger
+
+	jobs    chan actionJob
+	wg      sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+	mu      sync.RWMutex
+	stopped bool
+
+	// remediationLocks serialise the decisions to run each remediation action of a check, so their
+	// limits hold across workers
+	remediationMu    sync.Mutex
+	remediationLocks map[string]*sync.Mutex
+
+	groupMu sync.Mutex
+	groups  map[string]*pendingGroup
+
+	// replaying holds the ids of the failed actions being replayed, so each is only delivered once
+	replayMu  sync.Mutex
+	replaying map[string]struct{}
+}
+
+func NewDispatcher(conf *Config, s *store.BoltStore, actioners map[string]actions.Actioner, logger *log.Logger) *Dispatcher {
+	queueSize := conf.ActionQueueSize
+	if queueSize <= 0 {
+		queueSize = defaultActionQueueSize
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		conf:      conf,
+		store:     s,
+		actioners: actioners,
+		logger:    logger,
+		jobs:      make(chan actionJob, queueSize),
+		ctx:       ctx,
+		cancel:    cancel,
+		groups:    map[string]*pendingGroup{},
+		replaying: map[string]struct{}{},
+
+		remediationLocks: map[string]*sync.Mutex{},
+	}
+}
+
+// Start launches the workers delivering queued actions
+func (d *Dispatcher) Start() {
+	workers := d.conf.ActionWorkers
+	if workers <= 0 {
+		workers = defaultActionWorkers
+	}
+	for i := 0; i < workers; i++ {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			for job := range d.jobs {
+				actionQueueDepth.Set(float64(len(d.jobs)))
+				d.run(job)
+			}
+		}()
+	}
+}
+
+// Shutdown stops accepting actions and waits for the queued ones to be delivered. If ctx expires
+// first, in-flight deliveries are cancelled and end up in the failed action queue.
+func (d *Dispatcher) Shutdown(ctx context.Context) {
+	d.flushGroups()
+	d.mu.Lock()
+	if d.stopped {
+		d.mu.Unlock()
+		return
+	}
+	d.stopped = true
+	close(d.jobs)
+	d.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		d.logger.Info("Action queue drained")
+	case <-ctx.Done():
+		d.logger.Warn("Action queue not drained in time, cancelling in-flight actions", "remaining", len(d.jobs))
+		d.cancel()
+		<-drained
+	}
+	d.cancel()
+}
+
+// Dispatch queues the given actions with the payload of the check run, returning the storage keys
+// their outputs will be stored under. Actions that do not fit in the queue are persisted as failed.
+// Actions grouping their notifications are held back until the wait of their group is over.
+// Failed deliveries of the same actions for earlier runs are superseded and dropped.
+func (d *Dispatcher) Dispatch(c *algochecks.Check, checkActions []actions.ActionMeta, payload *actions.Payload) []string {
+	actionKeys := []string{}
+	if len(checkActions) == 0 {
+		return actionKeys
+	}
+	names := []string{}
+	for _, a := range checkActions {
+		names = append(names, a.Name)
+	}
+	d.dropFailed(c.Name, names, func(f *store.FailedAction) bool { return true })
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, a := range checkActions {
+		actionPayload := *payload
+		actionPayload.ActionName = a.Name
+		job := actionJob{
+			checkName: c.Name,
+			debug:     c.Debug,
+			timeout:   checkTimeout(d.conf, c),
+			key:       store.ActionKey(c.Name, a.Name, payload.Output.Timestamp),
+			action:    a,
+			payload:   &actionPayload,
+		}
+		if a.Group != nil && !d.stopped {
+			actionKeys = append(actionKeys, d.group(c, job))
+			continue
+		}
+		if d.enqueue(job) {
+			actionKeys = append(actionKeys, job.key)
+		}
+	}
+	return actionKeys
+}
+
+// enqueue queues the job for the workers, persisting it as failed if that is not possible. The
+// caller must hold d.mu for reading.
+func (d *Dispatcher) enqueue(job actionJob) bool {
+	logger := d.logger.WithPrefix(job.checkName)
+	reason := "dispatcher stopped"
+	if !d.stopped {
+		select {
+		case d.jobs <- job:
+			actionQueueDepth.Set(float64(len(d.jobs)))
+			logger.Info("Queued Action", "action", job.action.Name, "state", job.payload.State)
+			return true
+		default:
+			reason = "action queue full"
+		}
+	}
+	if job.action.Remediation != nil {
+		// Remediations are never run late, the check they were decided for may have recovered since
+		logger.Error("Dropping remediation", "action", job.action.Name, "reason", reason)
+		return false
+	}
+	logger.Error("Dropping action to the failed queue", "action", job.action.Name, "reason", reason)
+	d.persistFailed(job, 0, errors.New(reason))
+	return false
+}
+
+func (d *Dispatcher) run(job actionJob) {
+	logger := d.logger.WithPrefix(job.checkName)
+	logger.Info("Dispatching Action", "action", job.action.Name, "state", job.payload.State)
+	// Actions are bounded by the timeout of their check, counted from when they are picked up
+	ctx, cancel := context.WithTimeout(d.ctx, job.timeout)
+	defer cancel()
+	if job.action.Remediation != nil {
+		out, err := d.remediate(ctx, job.checkName, &job.action, job.payload)
+		if err != nil {
+			logger.Error("Remediation Failed with error", "name", job.action.Name, "err", err)
+		}
+		if err := d.store.PutAction(context.Background(), job.key, &out); err != nil {
+			logger.Error("Action Storage Failed with error", "name", job.action.Name, "err", err)
+		}
+		return
+	}
+	out, attempts, err := d.deliver(ctx, &job.action, job.payload)
+	if job.debug {
+		logger.Debugf("Action Output: %s", out.CombinedOut)
+	}
+	if err != nil {
+		logger.Error("Action Failed with error", "name", job.action.Name, "attempts", attempts, "err", err)
+		d.persistFailed(job, attempts, err)
+	}
+	// Store Values to Database
+	keys := job.memberKeys
+	if len(keys) == 0 {
+		keys = []string{job.key}
+	}
+	for _, key := range keys {
+		if err := d.store.PutAction(context.Background(), key, &out); err != nil {
+			logger.Error("Action Storage Failed with error", "name", job.action.Name, "err", err)
+		}
+	}
+}
+
+// Reload swaps in the config and actioners used by the actions delivered from now on
+func (d *Dispatcher) Reload(conf *Config, actioners map[string]actions.Actioner) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.conf = conf
+	d.actioners = actioners
+}
+
+func (d *Dispatcher) current() (*Config, map[string]actions.Actioner) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.conf, d.actioners
+}
+
+func (d *Dispatcher) persistFailed(job actionJob, attempts int, err error) {
+	failed := store.FailedAction{
+		CheckName: job.checkName,
+		Members:   job.members,
+		Action:    job.action,
+		Payload:   job.payload,
+		Error:     err.Error(),
+		Attempts:  attempts,
+		FailedAt:  time.Now().UTC(),
+	}
+	id, storeErr := d.store.PutFailedAction(context.Background(), &failed)
+	if storeErr != nil {
+		d.logger.Error("Failed Action Storage Failed with error", "check", job.checkName, "name", job.action.Name, "err", storeErr)
+		return
+	}
+	d.logger.Info("Failed Action queued for retry", "check", job.checkName, "name", job.action.Name, "id", id)
+	d.pruneFailed()
+}
+
+// Resolved drops the failed firing actions of a check that recovered, so they are not delivered
+// after the fact
+func (d *Dispatcher) Resolved(checkName string) {
+	d.dropFailed(checkName, nil, func(f *store.FailedAction) bool { return f.Payload.State != actions.StateResolved })
+}
+
+// dropFailed removes the failed actions of the check that match, among the ones of the given
+// action names or all of them if there are none
+func (d *Dispatcher) dropFailed(checkName string, actionNames []string, match func(*store.FailedAction) bool) {
+	failed, err := d.store.GetCheckFailedActions(context.Background(), checkName, actionNames...)
+	if err != nil {
+		d.logger.Error("Could not fetch failed actions", "err", err)
+		return
+	}
+	for _, f := range failed {
+		if !match(&f) {
+			continue
+		}
+		if len(f.Members) > 0 && withoutMember(&f, checkName) {
+			// Grouped notifications are kept for the members still firing
+			if _, err := d.store.PutFailedAction(context.Background(), &f); err != nil {
+				d.logger.Error("Could not update failed action", "id", f.ID, "err", err)
+				continue
+			}
+			d.logger.Info("Dropped check from failed group action", "check", checkName, "name", f.Action.Name, "id", f.ID)
+			continue
+		}
+		if err := d.store.DeleteFailedAction(context.Background(), f.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
+			d.logger.Error("Could not drop failed action", "id", f.ID, "err", err)
+			continue
+		}
+		d.logger.Info("Dropped superseded failed action", "check", checkName, "name", f.Action.Name, "id", f.ID)
+	}
+}
+
+// pruneFailed drops the failed actions older than the max age, then the oldest ones beyond the
+// max count
+func (d *Dispatcher) pruneFailed() {
+	conf, _ := d.current()
+	maxAge := conf.FailedActionMaxAge.Duration
+	if maxAge <= 0 {
+		maxAge = defaultFailedMaxAge
+	}
+	maxCount := conf.FailedActionMaxCount
+	if maxCount <= 0 {
+		maxCount = defaultFailedMaxCount
+	}
+	failed, err := d.store.GetFailedActions(context.Background())
+	if err != nil {
+		d.logger.Error("Could not fetch failed actions", "err", err)
+		return
+	}
+	for i, f := range failed {
+		// Failed actions are aged from the check run they notify of
+		at := f.FailedAt
+		if f.Payload != nil && !f.Payload.Output.Timestamp.IsZero() {
+			at = f.Payload.Output.Timestamp
+		}
+		if time.Since(at) <= maxAge && len(failed)-i <= maxCount {
+			continue
+		}
+		if err := d.store.DeleteFailedAction(context.Background(), f.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
+			d.logger.Error("Could not drop failed action", "id", f.ID, "err", err)
+			continue
+		}
+		d.logger.Warn("Dropped expired failed action", "check", f.CheckName, "name", f.Action.Name, "id", f.ID)
+	}
+}
+
+// deliver runs the action, retrying with an exponential backoff as configured on the action.
+// It returns the output of the last attempt and the number of attempts made.
+func (d *Dispatcher) deliver(ctx context.Context, a *actions.ActionMeta, payload *actions.Payload) (actions.Output, int, error) {
+	_, actioners := d.current()
+	actioner := actioners[a.Actioner]
+	if actioner == nil {
+		return actions.Output{RC: -1, Timestamp: time.Now().UTC()}, 0, fmt.Errorf("Actioner not found: %s", a.Actioner)
+	}
+	backoff := a.RetryBackoff.Duration
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	var out actions.Output
+	var err error
+	attempt := 0
+	for attempt < 1+max(a.Retries, 0) {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return out, attempt, ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		attempt++
+		out, err = d.attempt(ctx, actioner, a, payload)
+		if err == nil {
+			return out, attempt, nil
+		}
+		d.logger.Warn("Action attempt failed", "name", a.Name, "attempt", attempt, "err", err)
+	}
+	return out, attempt, err
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, actioner actions.Actioner, a *actions.ActionMeta, payload *actions.Payload) (actions.Output, error) {
+	conf, _ := d.current()
+	workDir, err := os.MkdirTemp(conf.BaseWorkingDir, "action-")
+	if err != nil {
+		return actions.Output{RC: -1, Timestamp: time.Now().UTC()}, fmt.Errorf("Unable to create Temp Dir: %v", err)
+	}
+	defer os.RemoveAll(workDir)
+	out, err := actioner.Action(ctx, a.Action, payload, a.Params, workDir)
+	if err == nil && out.Error != nil {
+		err = out.Error
+	}
+	return out, err
+}
+
+// Replay delivers a failed action again, removing it from the queue if it succeeds. Replays make
+// a single attempt, the failed queue being retried on its own interval.
+func (d *Dispatcher) Replay(ctx context.Context, id string) (actions.Output, error) {
+	if !d.claimReplay(id) {
+		return actions.Output{}, ErrReplayInProgress
+	}
+	defer d.releaseReplay(id)
+	failed, err := d.store.GetFailedAction(ctx, id)
+	if err != nil {
+		return actions.Output{}, err
+	}
+	if failed.Action.Remediation != nil {
+		// Queued before remediations stopped being persisted, and may no longer be warranted
+		d.logger.Warn("Dropping failed remediation, remediations are not replayed", "id", id, "check", failed.CheckName, "name", failed.Action.Name)
+		if err := d.store.DeleteFailedAction(ctx, id); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return actions.Output{}, err
+		}
+		return actions.Output{}, ErrRemediationReplay
+	}
+	once := failed.Action
+	once.Retries = 0
+	out, attempts, err := d.deliver(ctx, &once, failed.Payload)
+	key := store.ActionKey(failed.CheckName, failed.Action.Name, time.Now())
+	if storeErr := d.store.PutAction(ctx, key, &out); storeErr != nil {
+		d.logger.Error("Action Storage Failed with error", "name", failed.Action.Name, "err", storeErr)
+	}
+	if err != nil {
+		// The action may have been superseded or resolved while it was being replayed
+		if _, getErr := d.store.GetFailedAction(ctx, id); getErr != nil {
+			return out, err
+		}
+		failed.Attempts += attempts
+		failed.Error = err.Error()
+		failed.FailedAt = time.Now().UTC()
+		if _, storeErr := d.store.PutFailedAction(ctx, failed); storeErr != nil {
+			d.logger.Error("Failed Action Storage Failed with error", "id", id, "err", storeErr)
+		}
+		return out, err
+	}
+	if err := d.store.DeleteFailedAction(ctx, id); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return out, err
+	}
+	return out, nil
+}
+
+func (d *Dispatcher) claimReplay(id string) bool {
+	d.replayMu.Lock()
+	defer d.replayMu.Unlock()
+	if _, ok := d.replaying[id]; ok {
+		return false
+	}
+	d.replaying[id] = struct{}{}
+	return true
+}
+
+func (d *Dispatcher) releaseReplay(id string) {
+	d.replayMu.Lock()
+	defer d.replayMu.Unlock()
+	delete(d.replaying, id)
+}
+
+// RetryFailed replays all queued failed actions, once at start and then on every interval until ctx is done
+func (d *Dispatcher) RetryFailed(ctx context.Context) {
+	conf, _ := d.current()
+	interval := conf.FailedActionRetryInterval.Duration
+	if interval <= 0 {
+		interval = defaultFailedRetryInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		d.pruneFailed()
+		failed, err := d.store.GetFailedActions(ctx)
+		if err != nil {
+			d.logger.Error("Could not fetch failed actions", "err", err)
+		}
+		for _, f := range failed {
+			if ctx.Err() != nil {
+				return
+			}
+			if _, err := d.Replay(ctx, f.ID); err != nil {
+				d.logger.Warn("Retry of failed action failed", "id", f.ID, "check", f.CheckName, "action", f.Action.Name, "err", err)
+				continue
+			}
+			d.logger.Info("Retry of failed action succeeded", "id", f.ID, "check", f.CheckName, "action", f.Action.Name)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/tchaudhry91/algomon/actions"
+	"github.com/tchaudhry91/algomon/algochecks"
+	"github.com/tchaudhry91/algomon/measure"
+	"github.com/tchaudhry91/algomon/store"
+)
+
+// recordingActioner records the payloads it is given, failing while fail is set
+type recordingActioner struct {
+	mu       sync.Mutex
+	fail     bool
+	delay    time.Duration
+	payloads []*actions.Payload
+}
+
+func (r *recordingActioner) Action(ctx context.Context, action string, payload *actions.Payload, params map[string]string, workingDir string) (actions.Output, error) {
+	if r.delay > 0 {
+		time.Sleep(r.delay)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.payloads = append(r.payloads, payload)
+	if r.fail {
+		return actions.Output{RC: 1, Timestamp: time.Now().UTC()}, fmt.Errorf("delivery failed")
+	}
+	return actions.Output{RC: 0, Timestamp: time.Now().UTC()}, nil
+}
+
+func (r *recordingActioner) received() []*actions.Payload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*actions.Payload{}, r.payloads...)
+}
+
+func (r *recordingActioner) setFail(fail bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fail = fail
+}
+
+func newTestStore(t *testing.T) *store.BoltStore {
+	s, err := store.NewBoltStore(filepath.Join(t.TempDir(), "algomon.db"), log.Default())
+	if err != nil {
+		t.Fatalf("Could not open DB:%v", err)
+	}
+	return s
+}
+
+func newTestDispatcher(t *testing.T, conf *Config, actioner actions.Actioner) *Dispatcher {
+	conf.BaseWorkingDir = t.TempDir()
+	d := NewDispatcher(conf, newTestStore(t), map[string]actions.Actioner{"test": actioner}, log.Default())
+	d.Start()
+	t.Cleanup(func() { d.Shutdown(context.Background()) })
+	return d
+}
+
+func testCheck(name string, labels map[string]string) *algochecks.Check {
+	return &algochecks.Check{Name: name, Labels: labels, Interval: measure.Duration{Duration: time.Minute}}
+}
+
+func testJobPayload(c *algochecks.Check, state string) *actions.Payload {
+	return &actions.Payload{
+		State:  state,
+		Check:  actions.CheckDefinition{Name: c.Name, Labels: c.Labels},
+		Output: actions.CheckOutput{Status: algochecks.StatusFailed, Timestamp: time.Now().UTC()},
+	}
+}
+
+// waitFor polls cond until it holds or a second has passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func failedActionCount(t *testing.T, d *Dispatcher) int {
+	failed, err := d.store.GetFailedActions(context.Background())
+	if err != nil {
+		t.Fatalf("Could not fetch failed actions:%v", err)
+	}
+	return len(failed)
+}
+
+func TestDispatchPersistsFailedActions(t *testing.T) {
+	recorder := &recordingActioner{fail: true}
+	d := newTestDispatcher(t, &Config{}, recorder)
+	c := testCheck("API", nil)
+	d.Dispatch(c, []actions.ActionMeta{{Name: "Notify", Actioner: "test", Retries: 1, RetryBackoff: measure.Duration{Duration: time.Millisecond}}}, testJobPayload(c, actions.StateFiring))
+	waitFor(t, "failed action", func() bool { return failedActionCount(t, d) == 1 })
+	if len(recorder.received()) != 2 {
+		t.Fatalf("Expected 2 attempts, got %d", len(recorder.received()))
+	}
+}
+
+func TestResolvedDropsFailedFiringActions(t *testing.T) {
+	d := newTestDispatcher(t, &Config{}, &recordingActioner{})
+	api := testCheck("API", nil)
+	db := testCheck("DB", nil)
+	for _, c := range []*algochecks.Check{api, db} {
+		d.persistFailed(actionJob{checkName: c.Name, action: actions.ActionMeta{Name: "Notify"}, payload: testJobPayload(c, actions.StateFiring)}, 1, fmt.Errorf("failed"))
+	}
+	d.Resolved("API")
+	failed, _ := d.store.GetFailedActions(context.Background())
+	if len(failed) != 1 || failed[0].CheckName != "DB" {
+		t.Fatalf("Expected only the failed action of DB to remain, got %+v", failed)
+	}
+}
+
+func TestDispatchSupersedesFailedActions(t *testing.T) {
+	d := newTestDispatcher(t, &Config{}, &recordingActioner{})
+	c := testCheck("API", nil)
+	action := actions.ActionMeta{Name: "Notify", Actioner: "test"}
+	d.persistFailed(actionJob{checkName: c.Name, action: action, payload: testJobPayload(c, actions.StateFiring)}, 1, fmt.Errorf("failed"))
+	d.Dispatch(c, []actions.ActionMeta{action}, testJobPayload(c, actions.StateFiring))
+	if count := failedActionCount(t, d); count != 0 {
+		t.Fatalf("Expected the failed action to be superseded, got %d", count)
+	}
+}
+
+func TestPruneFailedActions(t *testing.T) {
+	d := newTestDispatcher(t, &Config{FailedActionMaxCount: 2, FailedActionMaxAge: measure.Duration{Duration: time.Hour}}, &recordingActioner{})
+	c := testCheck("API", nil)
+	old := testJobPayload(c, actions.StateFiring)
+	old.Output.Timestamp = time.Now().Add(-2 * time.Hour)
+	d.persistFailed(actionJob{checkName: c.Name, action: actions.ActionMeta{Name: "Old"}, payload: old}, 1, fmt.Errorf("failed"))
+	if count := failedActionCount(t, d); count != 0 {
+		t.Fatalf("Expected the expired failed action to be dropped, got %d", count)
+	}
+	for _, name := range []string{"A", "B", "C"} {
+		d.persistFailed(actionJob{checkName: c.Name, action: actions.ActionMeta{Name: name}, payload: testJobPayload(c, actions.StateFiring)}, 1, fmt.Errorf("failed"))
+	}
+	failed, _ := d.store.GetFailedActions(context.Background())
+	if len(failed) != 2 || failed[0].Action.Name != "B" || failed[1].Action.Name != "C" {
+		t.Fatalf("Expected the 2 newest failed actions to remain, got %+v", failed)
+	}
+}
+
+func TestReplayClaimsFailedAction(t *testing.T) {
+	recorder := &recordingActioner{delay: 100 * time.Millisecond}
+	d := newTestDispatcher(t, &Config{}, recorder)
+	c := testCheck("API", nil)
+	d.persistFailed(actionJob{checkName: c.Name, action: actions.ActionMeta{Name: "Notify", Actioner: "test", Retries: 3}, payload: testJobPayload(c, actions.StateFiring)}, 1, fmt.Errorf("failed"))
+	failed, _ := d.store.GetFailedActions(context.Background())
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := d.Replay(context.Background(), failed[0].ID)
+			errs <- err
+		}()
+	}
+	results := []error{<-errs, <-errs}
+	if !errors.Is(results[0], ErrReplayInProgress) && !errors.Is(results[1], ErrReplayInProgress) {
+		t.Fatalf("Expected one of the concurrent replays to be refused, got %v", results)
+	}
+	if len(recorder.received()) != 1 {
+		t.Fatalf("Expected a single delivery, got %d", len(recorder.received()))
+	}
+	if count := failedActionCount(t, d); count != 0 {
+		t.Fatalf("Expected the replayed action to be removed, got %d", count)
+	}
+}
+
+func TestReplayMakesSingleAttempt(t *testing.T) {
+	recorder := &recordingActioner{fail: true}
+	d := newTestDispatcher(t, &Config{}, recorder)
+	c := testCheck("API", nil)
+	d.persistFailed(actionJob{checkName: c.Name, action: actions.ActionMeta{Name: "Notify", Actioner: "test", Retries: 3}, payload: testJobPayload(c, actions.StateFiring)}, 1, fmt.Errorf("failed"))
+	failed, _ := d.store.GetFailedActions(context.Background())
+	if _, err := d.Replay(context.Background(), failed[0].ID); err == nil {
+		t.Fatalf("Expected the replay to fail")
+	}
+	if len(recorder.received()) != 1 {
+		t.Fatalf("Expected a single attempt, got %d", len(recorder.received()))
+	}
+	failed, _ = d.store.GetFailedActions(context.Background())
+	if len(failed) != 1 || failed[0].Attempts != 2 {
+		t.Fatalf("Expected the failed action to be kept with 2 attempts, got %+v", failed)
+	}
+}
+
+func TestDispatchIsTheOnlyRetryLayer(t *testing.T) {
+	var posts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	webhook := actions.Build(actions.ActionerMeta{Type: "webhook", Params: map[string]string{"url": srv.URL}}, nil, log.Default())
+	d := newTestDispatcher(t, &Config{}, webhook)
+	c := testCheck("API", nil)
+	d.Dispatch(c, []actions.ActionMeta{{Name: "Notify", Actioner: "test", Retries: 2, RetryBackoff: measure.Duration{Duration: time.Millisecond}}}, testJobPayload(c, actions.StateFiring))
+	waitFor(t, "failed action", func() bool { return failedActionCount(t, d) == 1 })
+	if posts.Load() != 3 {
+		t.Fatalf("Expected 3 posts for 2 retries, got %d", posts.Load())
+	}
+}
+
+func TestDispatchSupersedesOnlyItsActions(t *testing.T) {
+	d := newTestDispatcher(t, &Config{}, &recordingActioner{})
+	api := testCheck("API", nil)
+	db := testCheck("DB", nil)
+	for _, c := range []*algochecks.Check{api, db} {
+		for _, name := range []string{"Notify", "Page"} {
+			d.persistFailed(actionJob{checkName: c.Name, action: actions.ActionMeta{Name: name}, payload: testJobPayload(c, actions.StateFiring)}, 1, fmt.Errorf("failed"))
+		}
+	}
+	d.Dispatch(api, []actions.ActionMeta{{Name: "Notify", Actioner: "test"}}, testJobPayload(api, actions.StateFiring))
+	remaining := []string{}
+	failed, _ := d.store.GetFailedActions(context.Background())
+	for _, f := range failed {
+		remaining = append(remaining, f.CheckName+"/"+f.Action.Name)
+	}
+	if strings.Join(remaining, ",") != "API/Page,DB/Notify,DB/Page" {
+		t.Fatalf("Expected only the failed Notify of API to be superseded, got %v", remaining)
+	}
+	if failed, _ := d.store.GetCheckFailedActions(context.Background(), "API"); len(failed) != 1 || failed[0].Action.Name != "Page" {
+		t.Fatalf("Expected the index to follow the deletion, got %+v", failed)
+	}
+	d.Resolved("DB")
+	if failed, _ := d.store.GetCheckFailedActions(context.Background(), "DB"); len(failed) != 0 {
+		t.Fatalf("Expected the failed actions of DB to be dropped, got %+v", failed)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// expandPattern matches ${ENV_VAR} and ${file:path} references, and their $${...} escapes. Other
+// uses of ${...}, e.g. shell defaults like ${VAR:-default}, are left as they are.
+var expandPattern = regexp.MustCompile(`\$?\$\{(file:[^}]+|[A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandConfigValues replaces the references in every string of a decoded config with the value
+// of the environment variable, or the contents of the file they name. Relative file paths are
+// resolved from dir, the directory of the config file. A reference can be escaped as $${...}.
+func expandConfigValues(v any, dir string) (any, error) {
+	return expandValue(v, dir, "")
+}
+
+// expandValue expands the value at the given path of the config, e.g. checks[0].actions[1].params.url
+func expandValue(v any, dir string, path string) (any, error) {
+	switch val := v.(type) {
+	case string:
+		expanded, err := expandString(val, dir)
+		if err != nil {
+			return nil, fmt.Errorf("%s %v", path, err)
+		}
+		return expanded, nil
+	case map[string]any:
+		for k, item := range val {
+			key := k
+			if path != "" {
+				key = path + "." + k
+			}
+			expanded, err := expandValue(item, dir, key)
+			if err != nil {
+				return nil, err
+			}
+			val[k] = expanded
+		}
+	case []any:
+		for i, item := range val {
+			expanded, err := expandValue(item, dir, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			val[i] = expanded
+		}
+	}
+	return v, nil
+}
+
+func expandString(s string, dir string) (string, error) {
+	var err error
+	expanded := expandPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+		name := ref[2 : len(ref)-1]
+		if path, ok := strings.CutPrefix(name, "file:"); ok {
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			data, readErr := os.ReadFile(path)
+			if readErr != nil {
+				err = fmt.Errorf("could not read referenced file: %v", readErr)
+				return ""
+			}
+			return strings.TrimRight(string(data), "\r\n")
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			err = fmt.Errorf("references undefined environment variable %q", name)
+			return ""
+		}
+		return value
+	})
+	return expanded, err
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpandString(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "token"), "s3cret\n")
+	t.Setenv("ALGOMON_TEST_HOST", "prom.local")
+	tests := []struct {
+		in       string
+		expected string
+	}{
+		{"http://${ALGOMON_TEST_HOST}:9090", "http://prom.local:9090"},
+		{"Bearer ${file:token}", "Bearer s3cret"},
+		{"${file:" + filepath.Join(dir, "token") + "}", "s3cret"},
+		{"$${ALGOMON_TEST_HOST}", "${ALGOMON_TEST_HOST}"},
+		{"$${file:token}", "${file:token}"},
+		{"echo ${VAR:-default} ${1}", "echo ${VAR:-default} ${1}"},
+		{"rate(http_requests_total[5m])", "rate(http_requests_total[5m])"},
+	}
+	for _, tt := range tests {
+		out, err := expandString(tt.in, dir)
+		if err != nil {
+			t.Fatalf("Could not expand %q:%v", tt.in, err)
+		}
+		if out != tt.expected {
+			t.Fatalf("Expected %q to expand to %q, got %q", tt.in, tt.expected, out)
+		}
+	}
+}
+
+func TestExpandConfigValuesErrors(t *testing.T) {
+	os.Unsetenv("ALGOMON_TEST_UNDEFINED")
+	tests := []struct {
+		raw      any
+		expected string
+	}{
+		{
+			map[string]any{"checks": []any{map[string]any{"actions": []any{map[string]any{"params": map[string]any{"url": "${ALGOMON_TEST_UNDEFINED}"}}}}}},
+			`checks[0].actions[0].params.url references undefined environment variable "ALGOMON_TEST_UNDEFINED"`,
+		},
+		{
+			map[string]any{"templates": map[string]any{"body": "${file:missing.tmpl}"}},
+			`templates.body could not read referenced file`,
+		},
+	}
+	for _, tt := range tests {
+		_, err := expandConfigValues(tt.raw, t.TempDir())
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Fatalf("Expected error %q, got %v", tt.expected, err)
+		}
+	}
+}
+
+func TestReadYAMLConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("ALGOMON_TEST_WEBHOOK", "http://hooks.local/alert")
+	path := filepath.Join(dir, "algomon.yaml")
+	writeFile(t, path, `
+checks:
+  - &defaults
+    name: DB
+    algorithmer_type: python
+    interval: 1m
+  - <<: *defaults
+    name: API
+    algorithm: offset_threshold
+    algorithm_params:
+      threshold: 0.95
+      window: 5
+      strict: true
+    actions:
+      - name: Notify
+        actioner: webhook
+        retries: 2
+        params:
+          url: ${ALGOMON_TEST_WEBHOOK}
+          port: 587
+action_workers: 8
+`)
+	conf, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("Could not load config:%v", err)
+	}
+	c := conf.Checks[1]
+	if c.Name != "API" || c.AlgorithmerType != "python" || c.Interval.Duration != time.Minute {
+		t.Fatalf("Unexpected check: %+v", c)
+	}
+	params := map[string]string{"threshold": "0.95", "window": "5", "strict": "true"}
+	for k, v := range params {
+		if c.AlgorithmParams[k] != v {
+			t.Fatalf("Expected algorithm param %s to be %q, got %q", k, v, c.AlgorithmParams[k])
+		}
+	}
+	a := c.Actions[0]
+	if a.Retries != 2 || a.Params["url"] != "http://hooks.local/alert" || a.Params["port"] != "587" {
+		t.Fatalf("Unexpected action: %+v", a)
+	}
+	if conf.ActionWorkers != 8 {
+		t.Fatalf("Expected 8 action workers, got %d", conf.ActionWorkers)
+	}
+}
+
+func TestReadConfigTypeErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "algomon.yaml")
+	writeFile(t, path, "checks:\n  - name: API\n    actions:\n      - name: Notify\n        retries: many\n")
+	_, _, err := readConfigFile(path)
+	if err == nil || !strings.Contains(err.Error(), "checks.0.actions.0.retries") {
+		t.Fatalf("Expected the error to name the field, got %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tchaudhry91/algomon/actions"
+	"github.com/tchaudhry91/algomon/algochecks"
+	"github.com/tchaudhry91/algomon/store"
+)
+
+var defaultGroupWait = 30 * time.Second
+
+// pendingGroup collects the notifications of an action sharing a group key until its wait is over
+type pendingGroup struct {
+	job      actionJob
+	payloads []*actions.Payload
+	// keys are the storage keys of the members, each storing the output of the combined notification
+	keys  []string
+	timer *time.Timer
+}
+
+// groupKey renders the values the check has for the keys of the policy, e.g. datasource=prom,team=sre
+func groupKey(c *algochecks.Check, policy *actions.GroupPolicy, state string) string {
+	parts := []string{}
+	for _, by := range policy.By {
+		var value string
+		switch by {
+		case "datasource":
+			datasources := []string{}
+			for _, i := range c.Inputs {
+				datasources = append(datasources, i.Datasource)
+			}
+			sort.Strings(datasources)
+			value = strings.Join(datasources, "+")
+		case "check":
+			value = c.Name
+		case "state":
+			value = state
+		default:
+			value = c.Labels[by]
+		}
+		parts = append(parts, by+"="+value)
+	}
+	return strings.Join(parts, ",")
+}
+
+// group adds the job to the pending group of its action and key, starting the group if it is the
+// first one. It returns the storage key the output of the combined notification will be stored
+// under for the check. The caller must hold d.mu for reading.
+func (d *Dispatcher) group(c *algochecks.Check, job actionJob) string {
+	key := groupKey(c, job.action.Group, job.payload.State)
+	// Checks only share a group if their action is defined the same way, as the group is sent
+	// with the definition of its first member
+	definition, _ := json.Marshal(job.action)
+	id := string(definition) + "/" + job.payload.State + "/" + key
+
+	d.groupMu.Lock()
+	defer d.groupMu.Unlock()
+	if pending, ok := d.groups[id]; ok {
+		pending.payloads = append(pending.payloads, job.payload)
+		pending.keys = append(pending.keys, job.key)
+		return job.key
+	}
+	wait := job.action.Group.Wait.Duration
+	if wait <= 0 {
+		wait = defaultGroupWait
+	}
+	job.payload.GroupKey = key
+	pending := &pendingGroup{job: job, payloads: []*actions.Payload{job.payload}, keys: []string{job.key}}
+	pending.timer = time.AfterFunc(wait, func() { d.flushGroup(id) })
+	d.groups[id] = pending
+	d.logger.Info("Grouping Action", "action", job.action.Name, "group", key, "wait", wait)
+	return job.key
+}
+
+// flushGroup queues the combined notification of a pending group
+func (d *Dispatcher) flushGroup(id string) {
+	d.groupMu.Lock()
+	pending, ok := d.groups[id]
+	delete(d.groups, id)
+	d.groupMu.Unlock()
+	if !ok {
+		return
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	d.enqueue(pending.combined())
+}
+
+// flushGroups queues the combined notifications of all pending groups without waiting for them
+func (d *Dispatcher) flushGroups() {
+	d.groupMu.Lock()
+	ids := make([]string, 0, len(d.groups))
+	for id, pending := range d.groups {
+		pending.timer.Stop()
+		ids = append(ids, id)
+	}
+	d.groupMu.Unlock()
+	for _, id := range ids {
+		d.flushGroup(id)
+	}
+}
+
+// combined returns the job of the group carrying the payloads and checks of all its members
+func (g *pendingGroup) combined() actionJob {
+	job := g.job
+	payload := *job.payload
+	payload.Grouped = g.payloads
+	job.payload = &payload
+	job.members = groupMembers(g.payloads)
+	job.memberKeys = g.keys
+	return job
+}
+
+// groupMembers returns the names of the checks of the grouped payloads, in order
+func groupMembers(payloads []*actions.Payload) []string {
+	members := []string{}
+	for _, p := range payloads {
+		if !slices.Contains(members, p.Check.Name) {
+			members = append(members, p.Check.Name)
+		}
+	}
+	return members
+}
+
+// withoutMember removes the check from the failed group notification, returning false once no
+// member is left
+func withoutMember(f *store.FailedAction, checkName string) bool {
+	f.Members = slices.DeleteFunc(f.Members, func(m string) bool { return m == checkName })
+	grouped := slices.DeleteFunc(slices.Clone(f.Payload.Grouped), func(p *actions.Payload) bool { return p.Check.Name == checkName })
+	if len(f.Members) == 0 || len(grouped) == 0 {
+		return false
+	}
+	// The group is described by its first remaining member
+	payload := *grouped[0]
+	payload.GroupKey = f.Payload.GroupKey
+	payload.Grouped = grouped
+	f.Payload = &payload
+	f.CheckName = f.Members[0]
+	return true
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tchaudhry91/algomon/actions"
+	"github.com/tchaudhry91/algomon/algochecks"
+	"github.com/tchaudhry91/algomon/measure"
+)
+
+func groupedAction(name string, params map[string]string) actions.ActionMeta {
+	return actions.ActionMeta{
+		Name:     name,
+		Actioner: "test",
+		Params:   params,
+		Group:    &actions.GroupPolicy{By: []string{"team"}, Wait: measure.Duration{Duration: 50 * time.Millisecond}},
+	}
+}
+
+func TestDispatchGroupsByKey(t *testing.T) {
+	recorder := &recordingActioner{}
+	d := newTestDispatcher(t, &Config{}, recorder)
+	action := groupedAction("Chat", nil)
+	api := testCheck("API", map[string]string{"team": "sre"})
+	db := testCheck("DB", map[string]string{"team": "sre"})
+	web := testCheck("Web", map[string]string{"team": "web"})
+	for _, c := range []*algochecks.Check{api, db, web} {
+		d.Dispatch(c, []actions.ActionMeta{action}, testJobPayload(c, actions.StateFiring))
+	}
+	if len(recorder.received()) != 0 {
+		t.Fatalf("Expected grouped notifications to wait, got %d", len(recorder.received()))
+	}
+	waitFor(t, "grouped notifications", func() bool { return len(recorder.received()) == 2 })
+
+	sizes := map[string]int{}
+	for _, p := range recorder.received() {
+		sizes[p.GroupKey] = len(p.Grouped)
+	}
+	if sizes["team=sre"] != 2 || sizes["team=web"] != 1 {
+		t.Fatalf("Expected 2 checks grouped for sre and 1 for web, got %v", sizes)
+	}
+}
+
+func TestDispatchGroupsOnlySameDefinition(t *testing.T) {
+	recorder := &recordingActioner{}
+	d := newTestDispatcher(t, &Config{}, recorder)
+	api := testCheck("API", map[string]string{"team": "sre"})
+	db := testCheck("DB", map[string]string{"team": "sre"})
+	d.Dispatch(api, []actions.ActionMeta{groupedAction("Chat", map[string]string{"url": "http://a"})}, testJobPayload(api, actions.StateFiring))
+	d.Dispatch(db, []actions.ActionMeta{groupedAction("Chat", map[string]string{"url": "http://b"})}, testJobPayload(db, actions.StateFiring))
+	waitFor(t, "grouped notifications", func() bool { return len(recorder.received()) == 2 })
+	for _, p := range recorder.received() {
+		if len(p.Grouped) != 1 {
+			t.Fatalf("Expected actions with different params not to be grouped, got %d checks", len(p.Grouped))
+		}
+	}
+}
+
+func TestDispatchGroupsFlushedOnShutdown(t *testing.T) {
+	recorder := &recordingActioner{}
+	d := newTestDispatcher(t, &Config{}, recorder)
+	action := groupedAction("Chat", nil)
+	action.Group.Wait = measure.Duration{Duration: time.Hour}
+	api := testCheck("API", map[string]string{"team": "sre"})
+	d.Dispatch(api, []actions.ActionMeta{action}, testJobPayload(api, actions.StateFiring))
+	d.Shutdown(context.Background())
+	if len(recorder.received()) != 1 {
+		t.Fatalf("Expected the pending group to be sent on shutdown, got %d notifications", len(recorder.received()))
+	}
+}
+
+func TestGroupKey(t *testing.T) {
+	c := testCheck("API", map[string]string{"team": "sre"})
+	c.Inputs = []measure.Measurement{{Datasource: "prom-b"}, {Datasource: "prom-a"}}
+	policy := &actions.GroupPolicy{By: []string{"datasource", "check", "state", "team", "missing"}}
+	key := groupKey(c, policy, actions.StateFiring)
+	expected := "datasource=prom-a+prom-b,check=API,state=firing,team=sre,missing="
+	if key != expected {
+		t.Fatalf("Expected group key %s, got %s", expected, key)
+	}
+}
+
+func TestGroupedFailureKeptForFiringMembers(t *testing.T) {
+	recorder := &recordingActioner{fail: true}
+	d := newTestDispatcher(t, &Config{}, recorder)
+	action := groupedAction("Chat", nil)
+	api := testCheck("API", map[string]string{"team": "sre"})
+	db := testCheck("DB", map[string]string{"team": "sre"})
+	keys := []string{}
+	for _, c := range []*algochecks.Check{api, db} {
+		keys = append(keys, d.Dispatch(c, []actions.ActionMeta{action}, testJobPayload(c, actions.StateFiring))...)
+	}
+	if len(keys) != 2 || keys[0] == keys[1] || !strings.HasPrefix(keys[1], "DB_") {
+		t.Fatalf("Expected each member to have its own action key, got %v", keys)
+	}
+	waitFor(t, "failed group action", func() bool { return failedActionCount(t, d) == 1 })
+	for _, c := range []string{"API", "DB"} {
+		if failed, _ := d.store.GetCheckFailedActions(context.Background(), c); len(failed) != 1 {
+			t.Fatalf("Expected the failed group action to be found for %s, got %d", c, len(failed))
+		}
+	}
+
+	d.Resolved("API")
+	failed, _ := d.store.GetFailedActions(context.Background())
+	if len(failed) != 1 || failed[0].CheckName != "DB" || !reflect.DeepEqual(failed[0].Members, []string{"DB"}) ||
+		len(failed[0].Payload.Grouped) != 1 || failed[0].Payload.Check.Name != "DB" {
+		t.Fatalf("Expected the failed group action to be kept for DB only, got %+v", failed)
+	}
+	if failed, _ := d.store.GetCheckFailedActions(context.Background(), "API"); len(failed) != 0 {
+		t.Fatalf("Expected the resolved member to leave the index, got %d", len(failed))
+	}
+
+	d.Resolved("DB")
+	if count := failedActionCount(t, d); count != 0 {
+		t.Fatalf("Expected the failed group action to be dropped once all members resolved, got %d", count)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tchaudhry91/algomon/algochecks"
+	"github.com/tchaudhry91/algomon/measure"
+	"github.com/tchaudhry91/algomon/store"
+)
+
+// InhibitRule suppresses the actions of the target checks while a source check is failing.
+// Checks are selected by name or by labels, and the labels listed in Equal must have the same
+// value on both checks, e.g. to only inhibit checks of the same service.
+//
+// Inhibition is decided when a target runs, from the last run of the sources. So a target failing
+// just before its source is not left notifying, the actions of a target that starts failing are
+// held back for Wait, giving the sources a run to fail first.
+type InhibitRule struct {
+	Source CheckSelector `json:"source"`
+	Target CheckSelector `json:"target"`
+	Equal  []string      `json:"equal"`
+	// Wait defaults to the longest interval of the source checks, 0 notifying targets right away
+	Wait *measure.Duration `json:"wait"`
+}
+
+// sources returns the checks that inhibit c by this rule, none if it is not a target
+func (r *InhibitRule) sources(conf *Config, c *algochecks.Check) []*algochecks.Check {
+	if !r.Target.selects(c) {
+		return nil
+	}
+	sources := []*algochecks.Check{}
+	for i := range conf.Checks {
+		source := &conf.Checks[i]
+		if source.Name != c.Name && r.Source.selects(source) && equalLabels(source, c, r.Equal) {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// CheckSelector selects the checks named in Checks, or the checks whose labels match all of Match
+type CheckSelector struct {
+	Checks []string          `json:"checks"`
+	Match  map[string]string `json:"match"`
+}
+
+func (cs *CheckSelector) selects(c *algochecks.Check) bool {
+	if len(cs.Checks) == 0 && len(cs.Match) == 0 {
+		return false
+	}
+	for _, name := range cs.Checks {
+		if name == c.Name {
+			return true
+		}
+	}
+	if len(cs.Match) == 0 {
+		return false
+	}
+	for k, v := range cs.Match {
+		if c.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (cs *CheckSelector) validate(checks map[string]struct{}) error {
+	if len(cs.Checks) == 0 && len(cs.Match) == 0 {
+		return fmt.Errorf("selects no checks")
+	}
+	for _, name := range cs.Checks {
+		if _, ok := checks[name]; !ok {
+			return fmt.Errorf("selects undefined check %q", name)
+		}
+	}
+	return nil
+}
+
+// inhibitedBy returns the name of a failing check that inhibits the actions of c, if any. The
+// status of the source checks is read from the store, as of their last run.
+func inhibitedBy(ctx context.Context, conf *Config, s *store.BoltStore, c *algochecks.Check) string {
+	for _, rule := range conf.InhibitRules {
+		for _, source := range rule.sources(conf, c) {
+			status, err := s.GetCheckStatus(ctx, source.Name)
+			if err == nil && status.Failing() {
+				return source.Name
+			}
+		}
+	}
+	return ""
+}
+
+// inhibitionWait returns how long the actions of c are held back once it starts failing, in case
+// a check inhibiting it has not run since. Cron sources only count through an explicit Wait.
+func inhibitionWait(conf *Config, c *algochecks.Check) time.Duration {
+	var wait time.Duration
+	for _, rule := range conf.InhibitRules {
+		sources := rule.sources(conf, c)
+		if len(sources) == 0 {
+			continue
+		}
+		if rule.Wait != nil {
+			wait = max(wait, rule.Wait.Duration)
+			continue
+		}
+		for _, source := range sources {
+			wait = max(wait, source.Interval.Duration)
+		}
+	}
+	return wait
+}
+
+func equalLabels(a *algochecks.Check, b *algochecks.Check, labels []string) bool {
+	for _, l := range labels {
+		if a.Labels[l] != b.Labels[l] {
+			return false
+		}
+	}
+	return true
+}
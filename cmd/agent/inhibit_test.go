@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/tchaudhry91/algomon/actions"
+	"github.com/tchaudhry91/algomon/algochecks"
+	"github.com/tchaudhry91/algomon/measure"
+)
+
+// stepAlgorithmer fails while failing is set, stamping its outputs with now
+type stepAlgorithmer struct {
+	failing bool
+	now     time.Time
+}
+
+func (a *stepAlgorithmer) ApplyAlgorithm(ctx context.Context, algorithm string, algorithmParams map[string]string, inputs map[string]measure.Result, workingDir string) (algochecks.Output, error) {
+	if a.failing {
+		return algochecks.Output{Status: algochecks.StatusFailed, RC: 1, Timestamp: a.now}, nil
+	}
+	return algochecks.Output{Status: algochecks.StatusSuccess, Timestamp: a.now}, nil
+}
+
+func TestCheckSelector(t *testing.T) {
+	c := testCheck("API", map[string]string{"team": "api", "severity": "critical"})
+	tests := []struct {
+		name     string
+		selector CheckSelector
+		expected bool
+	}{
+		{"empty", CheckSelector{}, false},
+		{"by name", CheckSelector{Checks: []string{"DB", "API"}}, true},
+		{"other name", CheckSelector{Checks: []string{"DB"}}, false},
+		{"by labels", CheckSelector{Match: map[string]string{"team": "api", "severity": "critical"}}, true},
+		{"partial labels", CheckSelector{Match: map[string]string{"team": "api", "severity": "warning"}}, false},
+		{"missing label", CheckSelector{Match: map[string]string{"service": ""}}, true},
+		{"name or labels", CheckSelector{Checks: []string{"DB"}, Match: map[string]string{"team": "api"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.selector.selects(c); got != tt.expected {
+				t.Fatalf("Expected selects to be %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestEqualLabels(t *testing.T) {
+	a := testCheck("API", map[string]string{"service": "shop", "team": "api"})
+	b := testCheck("DB", map[string]string{"service": "shop", "team": "db"})
+	if !equalLabels(a, b, nil) || !equalLabels(a, b, []string{"service"}) || !equalLabels(a, b, []string{"region"}) {
+		t.Fatalf("Expected the checks to have equal labels")
+	}
+	if equalLabels(a, b, []string{"service", "team"}) {
+		t.Fatalf("Expected the team labels to differ")
+	}
+}
+
+func inhibitConfig() *Config {
+	return &Config{
+		Checks: []algochecks.Check{
+			*testCheck("DB", map[string]string{"service": "shop"}),
+			*testCheck("API", map[string]string{"service": "shop"}),
+			*testCheck("Search", map[string]string{"service": "search"}),
+		},
+		InhibitRules: []InhibitRule{{
+			Source: CheckSelector{Checks: []string{"DB"}},
+			Target: CheckSelector{Checks: []string{"API", "Search"}},
+			Equal:  []string{"service"},
+		}},
+	}
+}
+
+func TestInhibitedBy(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	conf := inhibitConfig()
+	api, search, db := &conf.Checks[1], &conf.Checks[2], &conf.Checks[0]
+
+	if source := inhibitedBy(ctx, conf, s, api); source != "" {
+		t.Fatalf("Expected a source that never ran not to inhibit, got %q", source)
+	}
+	s.PutCheck(ctx, db, &algochecks.Output{Status: algochecks.StatusFailed, Timestamp: time.Now().Add(-time.Minute)})
+	if source := inhibitedBy(ctx, conf, s, api); source != "DB" {
+		t.Fatalf("Expected the failing DB check to inhibit, got %q", source)
+	}
+	if source := inhibitedBy(ctx, conf, s, search); source != "" {
+		t.Fatalf("Expected a target of another service not to be inhibited, got %q", source)
+	}
+	if source := inhibitedBy(ctx, conf, s, db); source != "" {
+		t.Fatalf("Expected a check not to inhibit itself, got %q", source)
+	}
+	s.PutCheck(ctx, db, &algochecks.Output{Status: algochecks.StatusSuccess, Timestamp: time.Now()})
+	if source := inhibitedBy(ctx, conf, s, api); source != "" {
+		t.Fatalf("Expected the recovered DB check not to inhibit, got %q", source)
+	}
+}
+
+func TestInhibitionWait(t *testing.T) {
+	conf := inhibitConfig()
+	conf.Checks[0].Interval.Duration = 5 * time.Minute
+	if wait := inhibitionWait(conf, &conf.Checks[1]); wait != 5*time.Minute {
+		t.Fatalf("Expected the wait to default to the source interval, got %s", wait)
+	}
+	if wait := inhibitionWait(conf, &conf.Checks[2]); wait != 0 {
+		t.Fatalf("Expected no wait without sources, got %s", wait)
+	}
+	conf.InhibitRules[0].Wait = &measure.Duration{Duration: 0}
+	if wait := inhibitionWait(conf, &conf.Checks[1]); wait != 0 {
+		t.Fatalf("Expected an explicit wait of 0 to disable it, got %s", wait)
+	}
+}
+
+// inhibitRun runs the API check of inhibitConfig at now, returning the keys of the actions it dispatched
+func inhibitRun(t *testing.T, conf *Config, d *Dispatcher, algorithmer *stepAlgorithmer, now time.Time, failing bool) []string {
+	t.Helper()
+	algorithmer.now, algorithmer.failing = now, failing
+	c := &conf.Checks[1]
+	runCheck(context.Background(), c, conf, log.Default(), d.store, map[string]algochecks.Algorithmer{"step": algorithmer}, d, true)
+	status, err := d.store.GetCheckStatus(context.Background(), c.Name)
+	if err != nil {
+		t.Fatalf("Could not fetch the check status:%v", err)
+	}
+	return status.ActionKeys
+}
+
+func TestInhibitionSuppressesActions(t *testing.T) {
+	resolve := true
+	newConf := func() *Config {
+		conf := inhibitConfig()
+		conf.Checks[1].AlgorithmerType = "step"
+		conf.Checks[1].Actions = []actions.ActionMeta{{Name: "Page", Actioner: "test", SendResolved: &resolve}}
+		return conf
+	}
+	start := time.Now().UTC().Truncate(time.Second)
+	sourceFailing := func(d *Dispatcher, conf *Config, at time.Time) {
+		d.store.PutCheck(context.Background(), &conf.Checks[0], &algochecks.Output{Status: algochecks.StatusFailed, Timestamp: at})
+	}
+
+	t.Run("inhibited firing is not resolved", func(t *testing.T) {
+		conf := newConf()
+		d := newTestDispatcher(t, conf, &recordingActioner{})
+		sourceFailing(d, conf, start)
+		if keys := inhibitRun(t, conf, d, &stepAlgorithmer{}, start.Add(time.Second), true); len(keys) != 0 {
+			t.Fatalf("Expected the inhibited check not to act, got %v", keys)
+		}
+		if keys := inhibitRun(t, conf, d, &stepAlgorithmer{}, start.Add(2*time.Minute), false); len(keys) != 0 {
+			t.Fatalf("Expected no recovery for a firing never notified, got %v", keys)
+		}
+	})
+
+	t.Run("target failing before its source", func(t *testing.T) {
+		conf := newConf()
+		d := newTestDispatcher(t, conf, &recordingActioner{})
+		if keys := inhibitRun(t, conf, d, &stepAlgorithmer{}, start, true); len(keys) != 0 {
+			t.Fatalf("Expected the actions to be held for the source to run, got %v", keys)
+		}
+		sourceFailing(d, conf, start.Add(10*time.Second))
+		if keys := inhibitRun(t, conf, d, &stepAlgorithmer{}, start.Add(2*time.Minute), true); len(keys) != 0 {
+			t.Fatalf("Expected the check to be inhibited, got %v", keys)
+		}
+		if keys := inhibitRun(t, conf, d, &stepAlgorithmer{}, start.Add(3*time.Minute), false); len(keys) != 0 {
+			t.Fatalf("Expected no recovery for a firing never notified, got %v", keys)
+		}
+	})
+
+	t.Run("notified once the wait is over", func(t *testing.T) {
+		conf := newConf()
+		d := newTestDispatcher(t, conf, &recordingActioner{})
+		inhibitRun(t, conf, d, &stepAlgorithmer{}, start, true)
+		if keys := inhibitRun(t, conf, d, &stepAlgorithmer{}, start.Add(time.Minute), true); len(keys) != 1 {
+			t.Fatalf("Expected the check to act after the wait, got %v", keys)
+		}
+		// Inhibition starting after the notification does not hide the recovery
+		sourceFailing(d, conf, start.Add(90*time.Second))
+		inhibitRun(t, conf, d, &stepAlgorithmer{}, start.Add(2*time.Minute), true)
+		if keys := inhibitRun(t, conf, d, &stepAlgorithmer{}, start.Add(3*time.Minute), false); len(keys) != 1 {
+			t.Fatalf("Expected the recovery to be notified, got %v", keys)
+		}
+	})
+
+	t.Run("no wait", func(t *testing.T) {
+		conf := newConf()
+		conf.InhibitRules[0].Wait = &measure.Duration{}
+		d := newTestDispatcher(t, conf, &recordingActioner{})
+		if keys := inhibitRun(t, conf, d, &stepAlgorithmer{}, start, true); len(keys) != 1 {
+			t.Fatalf("Expected the check to act right away, got %v", keys)
+		}
+	})
+}
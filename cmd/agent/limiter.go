@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// ExecutionLimiter bounds the number of checks running at once, overall and per algorithmer.
+// Runs over the limits wait for a slot in the order they arrived.
+type ExecutionLimiter struct {
+	global         chan struct{}
+	perAlgorithmer map[string]chan struct{}
+}
+
+// NewExecutionLimiter builds the limiter for the configured limits, a limit of 0 being unbounded
+func NewExecutionLimiter(conf *Config) *ExecutionLimiter {
+	l := &ExecutionLimiter{perAlgorithmer: map[string]chan struct{}{}}
+	if conf.MaxConcurrentChecks > 0 {
+		l.global = make(chan struct{}, conf.MaxConcurrentChecks)
+	}
+	for _, a := range conf.Algorithmers {
+		if a.MaxConcurrent > 0 {
+			l.perAlgorithmer[a.Type] = make(chan struct{}, a.MaxConcurrent)
+		}
+	}
+	return l
+}
+
+// executionLimits returns the limits of the config by algorithmer type, the global one under ""
+func executionLimits(conf *Config) map[string]int {
+	limits := map[string]int{"": conf.MaxConcurrentChecks}
+	for _, a := range conf.Algorithmers {
+		if a.MaxConcurrent > 0 {
+			limits[a.Type] = a.MaxConcurrent
+		}
+	}
+	return limits
+}
+
+// Acquire waits for a slot to run a check of the given algorithmer, returning the func releasing it
+func (l *ExecutionLimiter) Acquire(ctx context.Context, algorithmerType string) (func(), error) {
+	start := time.Now()
+	checkQueueDepth.Inc()
+	defer checkQueueDepth.Dec()
+	defer func() { checkQueueWait.Observe(time.Since(start).Seconds()) }()
+
+	// The algorithmer slot is taken first, so runs waiting on it do not hold up other algorithmers
+	release := func() {}
+	if sem := l.perAlgorithmer[algorithmerType]; sem != nil {
+		select {
+		case sem <- struct{}{}:
+			release = func() { <-sem }
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if l.global != nil {
+		select {
+		case l.global <- struct{}{}:
+			releaseAlgorithmer := release
+			release = func() {
+				<-l.global
+				releaseAlgorithmer()
+			}
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+	return release, nil
+}
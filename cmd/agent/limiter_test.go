@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tchaudhry91/algomon/algochecks"
+)
+
+// acquireWithin tries to acquire a slot for the algorithmer, giving up after a short wait
+func acquireWithin(l *ExecutionLimiter, algorithmerType string) (func(), error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	return l.Acquire(ctx, algorithmerType)
+}
+
+func TestExecutionLimiter(t *testing.T) {
+	l := NewExecutionLimiter(&Config{
+		MaxConcurrentChecks: 3,
+		Algorithmers:        []algochecks.AlgorithmerMeta{{Type: "python", MaxConcurrent: 2}, {Type: "builtin"}},
+	})
+
+	// The algorithmer limit blocks the third python run
+	releases := []func(){}
+	for i := 0; i < 2; i++ {
+		release, err := acquireWithin(l, "python")
+		if err != nil {
+			t.Fatalf("Expected python run %d to get a slot:%v", i+1, err)
+		}
+		releases = append(releases, release)
+	}
+	if _, err := acquireWithin(l, "python"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the third python run to wait, got %v", err)
+	}
+
+	// The global limit blocks the fourth run, whatever its algorithmer
+	release, err := acquireWithin(l, "builtin")
+	if err != nil {
+		t.Fatalf("Expected the builtin run to get a slot:%v", err)
+	}
+	releases = append(releases, release)
+	if _, err := acquireWithin(l, "builtin"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the fourth run to wait on the global limit, got %v", err)
+	}
+
+	for _, release := range releases {
+		release()
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := acquireWithin(l, "builtin"); err != nil {
+			t.Fatalf("Expected all slots to be released:%v", err)
+		}
+	}
+}
+
+func TestExecutionLimiterCancelReleasesAlgorithmerSlot(t *testing.T) {
+	l := NewExecutionLimiter(&Config{
+		MaxConcurrentChecks: 2,
+		Algorithmers:        []algochecks.AlgorithmerMeta{{Type: "python", MaxConcurrent: 2}},
+	})
+	python, _ := acquireWithin(l, "python")
+	builtin, _ := acquireWithin(l, "builtin")
+	// Takes the second python slot, then gives it back when cancelled waiting on the global limit
+	if _, err := acquireWithin(l, "python"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the python run to wait on the global limit, got %v", err)
+	}
+	builtin()
+	release, err := acquireWithin(l, "python")
+	if err != nil {
+		t.Fatalf("Expected the python slot of the cancelled run to be released:%v", err)
+	}
+	release()
+	python()
+}
+
+func TestExecutionLimiterUnbounded(t *testing.T) {
+	l := NewExecutionLimiter(&Config{})
+	for i := 0; i < 10; i++ {
+		if _, err := acquireWithin(l, "python"); err != nil {
+			t.Fatalf("Expected no limit without limits configured:%v", err)
+		}
+	}
+}
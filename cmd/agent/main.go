@@ -3,7 +3,7 @@ package main
 import (
 	"context"
 	"embed"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/fs"
@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -38,86 +39,38 @@ var header = `
 var uiFiles embed.FS
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(validateCommand(os.Args[2:]))
+	}
 	logger := log.Default()
 	logger.SetPrefix("algomon")
 	logger.SetReportCaller(true)
-	var configF = flag.String("c", "algomon.json", "config file to use")
+	var configF = flag.String("c", "algomon.json", "config file, directory or glob of files to use")
 	var debugMode = flag.Bool("d", false, "debug logs on")
 	flag.Parse()
 	if *debugMode {
 		logger.SetLevel(log.DebugLevel)
 	}
 
-	config := Config{}
-	confData, err := os.ReadFile(*configF)
+	config, err := loadConfig(*configF)
 	if err != nil {
-		logger.Fatal("Error Reading Config File", "err", err)
-	}
-	if err = json.Unmarshal(confData, &config); err != nil {
-		logger.Fatal("Unable to Unmarshal Config", "err", err)
+		logger.Fatal("Could not load config", "err", err)
 	}
 	fmt.Print(header)
-	run(&config, logger)
+	run(*configF, config, logger)
 }
 
-var contexts = map[string]*context.CancelFunc{}
-
-func validateConfig(conf *Config) error {
-	datasources := make(map[string]struct{})
-	for _, d := range conf.Datasources {
-		if d.Name == "" {
-			return fmt.Errorf("datasource name cannot be empty")
-		}
-		datasources[d.Name] = struct{}{}
-	}
-
-	algorithmers := make(map[string]struct{})
-	for _, a := range conf.Algorithmers {
-		if a.Type == "" {
-			return fmt.Errorf("algorithmer type cannot be empty")
-		}
-		algorithmers[a.Type] = struct{}{}
-	}
-
-	actioners := make(map[string]struct{})
-	for _, a := range conf.Actioners {
-		if a.Type == "" {
-			return fmt.Errorf("actioner type cannot be empty")
-		}
-		actioners[a.Type] = struct{}{}
-	}
-
-	for _, c := range conf.Checks {
-		if c.Name == "" {
-			return fmt.Errorf("check name cannot be empty")
-		}
-		if _, ok := algorithmers[c.AlgorithmerType]; !ok {
-			return fmt.Errorf("check %q uses undefined algorithmer type %q", c.Name, c.AlgorithmerType)
-		}
-		for _, i := range c.Inputs {
-			if _, ok := datasources[i.Datasource]; !ok {
-				return fmt.Errorf("check %q uses undefined datasource %q", c.Name, i.Datasource)
-			}
-		}
-		for _, a := range c.Actions {
-			if _, ok := actioners[a.Actioner]; !ok {
-				return fmt.Errorf("check %q uses undefined actioner type %q", c.Name, a.Actioner)
-			}
-		}
-	}
-	return nil
-}
-
-func run(conf *Config, logger *log.Logger) {
-	if err := validateConfig(conf); err != nil {
+func run(configPath string, conf *Config, logger *log.Logger) {
+	if err := validateConfig(conf, false); err != nil {
 		logger.Fatal("Invalid configuration", "err", err)
 	}
+	applyRoutes(conf)
 
-	tickers := make([]*time.Ticker, 0, 1)
-	done := make(chan bool)
 	shutdown := make(chan error, 1)
 	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
 
 	addr := conf.APIListenAddr
 	if addr == "" {
@@ -129,8 +82,15 @@ func run(conf *Config, logger *log.Logger) {
 		logger.Fatal("Could not open database", "err", err)
 	}
 
+	agent, err := NewAgent(configPath, conf, s, logger)
+	if err != nil {
+		logger.Fatal("Could not start agent", "err", err)
+	}
+	retryCtx, retryCancel := context.WithCancel(context.Background())
+	defer retryCancel()
+
 	slogHandler := slog.New(logger.WithPrefix("APIServer"))
-	apiServer := NewAPIServer(s, conf, slogHandler)
+	apiServer := NewAPIServer(s, agent, slogHandler)
 
 	apiMux := http.NewServeMux()
 	apiMux.Handle("/metrics", promhttp.Handler())
@@ -153,63 +113,75 @@ func run(conf *Config, logger *log.Logger) {
 		}
 	}()
 
-	algorithmers := make(map[string]algochecks.Algorithmer)
-	for _, aa := range conf.Algorithmers {
-		algorithmers[aa.Type] = algochecks.Build(aa, logger)
-	}
-
-	actioners := make(map[string]actions.Actioner)
-	for _, aa := range conf.Actioners {
-		actioners[aa.Type] = actions.Build(aa, logger)
-	}
+	agent.Start()
+	go agent.dispatcher.RetryFailed(retryCtx)
 
-	for _, c := range conf.Checks {
-		ticker := time.NewTicker(c.Interval.Duration)
-		tickers = append(tickers, ticker)
-		logger.Info("Starting Check", "name", c.Name, "interval", c.Interval.Duration)
-		go func(c *algochecks.Check, logger *log.Logger) {
-			if c.Immediate {
-				err := runCheck(c, conf, logger, s, algorithmers, actioners)
-				if err != nil {
-					logger.Error("err", err)
-				}
+	for {
+		select {
+		case <-hangup:
+			logger.Info("Received SIGHUP, reloading config", "file", configPath)
+			if _, err := agent.Reload(); err != nil {
+				logger.Error("Reload failed, keeping the running config", "err", err)
+			}
+		case signalKill := <-interrupt:
+			logger.Info("Received Interrupt", "signal", signalKill)
+			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer shutdownCancel()
+			if err := server.Shutdown(shutdownCtx); err != nil {
+				logger.Error("HTTP server shutdown error", "err", err)
 			}
-			for {
-				select {
-				case <-done:
-					return
-				case <-ticker.C:
-					err := runCheck(c, conf, logger, s, algorithmers, actioners)
-					if err != nil {
-						logger.Error("err", err)
-					}
-				}
+			stopCtx, stopCancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer stopCancel()
+			agent.scheduler.Stop(stopCtx)
+			retryCancel()
+			drainTimeout := agent.Config().ActionDrainTimeout.Duration
+			if drainTimeout <= 0 {
+				drainTimeout = 30 * time.Second
 			}
-		}(&c, logger.WithPrefix(c.Name))
+			drainCtx, drainCancel := context.WithTimeout(context.Background(), drainTimeout)
+			defer drainCancel()
+			agent.dispatcher.Shutdown(drainCtx)
+			return
+		case err := <-shutdown:
+			logger.Error("err", err)
+			return
+		}
 	}
+}
 
-	select {
-	case signalKill := <-interrupt:
-		logger.Info("Received Interrupt", "signal", signalKill)
-		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer shutdownCancel()
-		if err := server.Shutdown(shutdownCtx); err != nil {
-			logger.Error("HTTP server shutdown error", "err", err)
-		}
-		for name, cancel := range contexts {
-			logger.Info("Cancelling", "name", name)
-			(*cancel)()
+// failingDependencies returns the dependencies of the check whose last run failed, timed out or was skipped.
+// Dependencies that have not run yet are assumed to be healthy.
+func failingDependencies(ctx context.Context, s *store.BoltStore, c *algochecks.Check) []string {
+	failing := []string{}
+	for _, name := range c.DependsOn {
+		status, err := s.GetCheckStatus(ctx, name)
+		if err != nil {
+			continue
 		}
-		for _, ticker := range tickers {
-			ticker.Stop()
+		if status.Failing() || status.Status == algochecks.StatusSkipped {
+			failing = append(failing, name)
 		}
-	case err := <-shutdown:
-		logger.Error("err", err)
 	}
+	return failing
+}
 
+// activeState evaluates the active times of a check, returning whether it should run at all
+// and whether its actions should be dispatched
+func activeState(c *algochecks.Check, now time.Time) (run bool, withActions bool) {
+	if c.ActiveTimes == nil {
+		return true, true
+	}
+	active, err := c.ActiveTimes.Active(now)
+	if err != nil || active {
+		return true, true
+	}
+	if c.ActiveTimes.SuppressMode() == algochecks.SuppressActions {
+		return true, false
+	}
+	return false, false
 }
 
-func runCheck(c *algochecks.Check, conf *Config, logger *log.Logger, s *store.BoltStore, algorithmers map[string]algochecks.Algorithmer, actioners map[string]actions.Actioner) error {
+func runCheck(ctx context.Context, c *algochecks.Check, conf *Config, logger *log.Logger, s *store.BoltStore, algorithmers map[string]algochecks.Algorithmer, dispatcher *Dispatcher, withActions bool) error {
 	algorithmer := algorithmers[c.AlgorithmerType]
 	if algorithmer == nil {
 		return fmt.Errorf("AlgorithmerType:%s not found", c.AlgorithmerType)
@@ -220,6 +192,26 @@ func runCheck(c *algochecks.Check, conf *Config, logger *log.Logger, s *store.Bo
 	failed := countFail.WithLabelValues(c.Name)
 	defer processed.Inc()
 
+	if failing := failingDependencies(ctx, s, c); len(failing) > 0 {
+		output := algochecks.Output{
+			Name:        c.Name,
+			Status:      algochecks.StatusSkipped,
+			Timestamp:   time.Now().UTC(),
+			RC:          -1,
+			CombinedOut: "Skipped, dependencies not healthy: " + strings.Join(failing, ", "),
+		}
+		outputKey, err := s.PutCheck(ctx, c, &output)
+		if err != nil {
+			logger.Error("Check Storage Failed", "err", err)
+		}
+		logger.Info("Skipped, dependencies not healthy", "depends_on", failing, "storage_key", outputKey)
+		return err
+	}
+
+	timeout := checkTimeout(conf, c)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
 	tempWorkDir, err := os.MkdirTemp(conf.BaseWorkingDir, c.Name+"-")
 	if err != nil {
 		failed.Inc()
@@ -227,10 +219,6 @@ func runCheck(c *algochecks.Check, conf *Config, logger *log.Logger, s *store.Bo
 	}
 	defer os.RemoveAll(tempWorkDir)
 
-	ctx := context.Background()
-	ctx, cancel := context.WithCancel(ctx)
-	contexts[c.Name] = &cancel
-
 	// Fetch inputs
 	inputs := map[string]measure.Result{}
 	for _, i := range c.Inputs {
@@ -246,55 +234,124 @@ func runCheck(c *algochecks.Check, conf *Config, logger *log.Logger, s *store.Bo
 		}
 		res, err := i.MeasureProm(ctx, api)
 		if err != nil {
+			if ctx.Err() != nil {
+				// Timeouts are recorded below
+				break
+			}
 			failed.Inc()
 			return fmt.Errorf("Failed to measure prometheus query: %v", err)
 		}
 		inputs[i.Name] = res
 	}
-	output, err := algorithmer.ApplyAlgorithm(ctx, c.Algorithm, c.AlgorithmParams, inputs, tempWorkDir)
+	var output algochecks.Output
+	if ctx.Err() == nil {
+		output, err = algorithmer.ApplyAlgorithm(ctx, c.Algorithm, c.AlgorithmParams, inputs, tempWorkDir)
+	}
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		failed.Inc()
+		return fmt.Errorf("Check run cancelled")
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		err = fmt.Errorf("Check timed out after %s", timeout)
+		output.Status = algochecks.StatusTimeout
+		output.RC = -1
+		output.Error = err.Error()
+		if output.Timestamp.IsZero() {
+			output.Timestamp = time.Now().UTC()
+		}
+	}
 	output.Name = c.Name
 	if c.Debug {
 		defer logger.Debugf("Output: %s", output.CombinedOut)
 	}
+	previousStatus := ""
+	if previous, err := s.GetCheckStatus(ctx, c.Name); err == nil {
+		previousStatus = previous.Status
+	}
 	if err != nil || output.RC != 0 {
 		failed.Inc()
 		logger.Error("Check failed", "name", c.Name, "err", err, "rc", output.RC)
 
-		for _, a := range c.Actions {
-			actioner := actioners[a.Actioner]
-			if actioner == nil {
-				logger.Error("Actioner not found", "type", a.Actioner)
-				continue
-			}
-			logger.Info("Dispatching Action", "action", a.Name)
-			out, err := actioner.Action(ctx, a.Action, output.CombinedOut, a.Params, tempWorkDir)
-			if c.Debug {
-				logger.Debugf("Action Output: %s", out.CombinedOut)
-			}
-			if err != nil {
-				logger.Error("Action Failed with error", "name", a.Name, "err", err)
-			}
-			// Store Values to Database
-			actionKey, err := s.PutAction(ctx, c.Name, &a, &out)
-			if err != nil {
-				logger.Error("Action Storage Failed with error", "name", a.Name, "err", err)
+		alert, err := s.GetAlertState(ctx, c.Name)
+		if err != nil {
+			alert = &algochecks.AlertState{FiringSince: output.Timestamp, Silent: true}
+		}
+		checkActions := c.Actions
+		if !withActions {
+			logger.Info("Outside of active times, suppressing actions")
+			checkActions = nil
+		} else if ack, err := s.GetAck(ctx, c.Name); err == nil {
+			logger.Info("Check acknowledged, suppressing actions", "by", ack.By)
+			checkActions = nil
+		} else if source := inhibitedBy(ctx, conf, s, c); source != "" {
+			logger.Info("Check inhibited, suppressing actions", "by", source)
+			checkActions = nil
+		} else if wait := inhibitionWait(conf, c); alert.Silent && output.Timestamp.Sub(alert.FiringSince) < wait {
+			logger.Info("Waiting for the inhibiting checks to run, holding actions", "wait", wait)
+			checkActions = nil
+		} else if len(c.Escalation) > 0 {
+			// Escalation only advances while the check is unacknowledged
+			if due := c.EscalationStepsDue(output.Timestamp.Sub(alert.FiringSince)); due > alert.Step {
+				logger.Info("Escalating", "step", due, "firing_since", alert.FiringSince)
+				alert.Step = due
+				alert.EscalatedAt = output.Timestamp
 			}
-			output.ActionKeys = append(output.ActionKeys, actionKey)
-			outputKey, err := s.PutCheck(ctx, c, &output)
-			if err != nil {
-				logger.Error("Check Storage Failed", "err", err)
+			checkActions = c.EscalatedActions(alert.Step)
+		}
+		if len(checkActions) > 0 {
+			alert.Silent = false
+		}
+		if err := s.PutAlertState(ctx, c.Name, alert); err != nil {
+			logger.Error("Alert State Storage Failed", "err", err)
+		}
+		payload := c.ActionPayload(actions.StateFiring, inputs, &output, previousStatus)
+		output.ActionKeys = dispatcher.Dispatch(c, checkActions, payload)
+		outputKey, err := s.PutCheck(ctx, c, &output)
+		if err != nil {
+			logger.Error("Check Storage Failed", "err", err)
+
+			logger.Error("Exited with failure", "storage_key", outputKey)
+		}
+		logger.Info("Exited with Error. Output Stored to Key", "storage_key", outputKey)
+		return err
+	}
 
-				logger.Error("Exited with failure", "storage_key", outputKey)
+	// The alert state outlives skipped runs, so recoveries after them are still notified
+	alert, alertErr := s.GetAlertState(ctx, c.Name)
+	recovered := previousStatus == algochecks.StatusFailed || previousStatus == algochecks.StatusTimeout || alertErr == nil
+	if recovered {
+		dispatcher.Resolved(c.Name)
+	}
+	if recovered && alertErr == nil && alert.Silent {
+		logger.Info("Firing was never notified, skipping the recovery actions")
+	} else if withActions && recovered {
+		// Only the escalation steps that were reached are told about the recovery
+		reached := 0
+		if alertErr == nil {
+			reached = alert.Step
+		}
+		resolveActions := []actions.ActionMeta{}
+		for _, a := range c.EscalatedActions(reached) {
+			// Remediations act on the systems, which need no fixing once the check recovered
+			if a.ResolvesOnRecovery() && a.Remediation == nil {
+				resolveActions = append(resolveActions, a)
 			}
-			logger.Info("Exited with Error. Output Stored to Key", "storage_key", outputKey)
-			return err
 		}
+		payload := c.ActionPayload(actions.StateResolved, inputs, &output, previousStatus)
+		output.ActionKeys = dispatcher.Dispatch(c, resolveActions, payload)
 	}
 
 	outputKey, err := s.PutCheck(ctx, c, &output)
 	if err != nil {
 		logger.Error("Check Storage Failed", "err", err)
 	}
+	// The check recovered, so any acknowledgement or escalation of the previous failure is done with
+	if err := s.DeleteAck(ctx, c.Name); err != nil {
+		logger.Error("Clearing acknowledgement failed", "err", err)
+	}
+	if err := s.DeleteAlertState(ctx, c.Name); err != nil {
+		logger.Error("Clearing alert state failed", "err", err)
+	}
 	logger.Info("Exited successfully. Output Stored to Key", "storage_key", outputKey)
 	succeeded.Inc()
 	return nil
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/tchaudhry91/algomon/actions"
+	"github.com/tchaudhry91/algomon/algochecks"
+	"github.com/tchaudhry91/algomon/measure"
+	"github.com/tchaudhry91/algomon/store"
+)
+
+// runTestCheck runs the check with the algorithmer, returning the output it stored
+func runTestCheck(t *testing.T, d *Dispatcher, conf *Config, c *algochecks.Check, algorithmer algochecks.Algorithmer) algochecks.Output {
+	t.Helper()
+	c.AlgorithmerType = "test"
+	runCheck(context.Background(), c, conf, log.Default(), d.store, map[string]algochecks.Algorithmer{"test": algorithmer}, d, true)
+	status, err := d.store.GetCheckStatus(context.Background(), c.Name)
+	if err != nil {
+		t.Fatalf("Could not fetch the check status:%v", err)
+	}
+	return status
+}
+
+func TestAckSuppressesActions(t *testing.T) {
+	resolve := true
+	c := testCheck("API", nil)
+	c.Actions = []actions.ActionMeta{{Name: "Page", Actioner: "test", SendResolved: &resolve}}
+	conf := &Config{Checks: []algochecks.Check{*c}}
+	d := newTestDispatcher(t, conf, &recordingActioner{})
+	start := time.Now().UTC().Truncate(time.Second)
+
+	if out := runTestCheck(t, d, conf, c, &stepAlgorithmer{failing: true, now: start}); len(out.ActionKeys) != 1 {
+		t.Fatalf("Expected the failing check to act, got %v", out.ActionKeys)
+	}
+	if err := d.store.PutAck(context.Background(), c.Name, &algochecks.Acknowledgement{By: "oncall", Timestamp: start}); err != nil {
+		t.Fatalf("Could not acknowledge:%v", err)
+	}
+	if out := runTestCheck(t, d, conf, c, &stepAlgorithmer{failing: true, now: start.Add(time.Minute)}); len(out.ActionKeys) != 0 {
+		t.Fatalf("Expected the acknowledged check not to act, got %v", out.ActionKeys)
+	}
+	if out := runTestCheck(t, d, conf, c, &stepAlgorithmer{now: start.Add(2 * time.Minute)}); len(out.ActionKeys) != 1 {
+		t.Fatalf("Expected the recovery to be notified, got %v", out.ActionKeys)
+	}
+	if _, err := d.store.GetAck(context.Background(), c.Name); err != store.ErrNotFound {
+		t.Fatalf("Expected the acknowledgement to be cleared on recovery, got %v", err)
+	}
+	if out := runTestCheck(t, d, conf, c, &stepAlgorithmer{failing: true, now: start.Add(3 * time.Minute)}); len(out.ActionKeys) != 1 {
+		t.Fatalf("Expected a new failure to act again, got %v", out.ActionKeys)
+	}
+}
+
+// slowAlgorithmer runs until its context is done
+type slowAlgorithmer struct{}
+
+func (a *slowAlgorithmer) ApplyAlgorithm(ctx context.Context, algorithm string, algorithmParams map[string]string, inputs map[string]measure.Result, workingDir string) (algochecks.Output, error) {
+	<-ctx.Done()
+	return algochecks.Output{RC: -1}, ctx.Err()
+}
+
+func TestCheckTimeout(t *testing.T) {
+	c := testCheck("API", nil)
+	c.Timeout = measure.Duration{Duration: 20 * time.Millisecond}
+	c.Actions = []actions.ActionMeta{{Name: "Page", Actioner: "test"}}
+	conf := &Config{Checks: []algochecks.Check{*c}}
+	d := newTestDispatcher(t, conf, &recordingActioner{})
+
+	out := runTestCheck(t, d, conf, c, &slowAlgorithmer{})
+	if out.Status != algochecks.StatusTimeout || out.RC != -1 || out.Error == "" || out.Timestamp.IsZero() {
+		t.Fatalf("Expected a timeout to be stored, got %+v", out)
+	}
+	if !out.Failing() || len(out.ActionKeys) != 1 {
+		t.Fatalf("Expected the timeout to fail the check and act, got %+v", out)
+	}
+	if _, err := d.store.GetAlertState(context.Background(), c.Name); err != nil {
+		t.Fatalf("Expected the timeout to fire the check:%v", err)
+	}
+}
+
+func TestCheckCancelledStoresNothing(t *testing.T) {
+	c := testCheck("API", nil)
+	c.AlgorithmerType = "test"
+	conf := &Config{Checks: []algochecks.Check{*c}}
+	d := newTestDispatcher(t, conf, &recordingActioner{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	err := runCheck(ctx, c, conf, log.Default(), d.store, map[string]algochecks.Algorithmer{"test": &slowAlgorithmer{}}, d, true)
+	if err == nil {
+		t.Fatalf("Expected the cancelled run to fail")
+	}
+	if _, err := d.store.GetCheckStatus(context.Background(), c.Name); err != store.ErrNotFound {
+		t.Fatalf("Expected nothing to be stored for a cancelled run, got %v", err)
+	}
+	if _, err := d.store.GetAlertState(context.Background(), c.Name); err != store.ErrNotFound {
+		t.Fatalf("Expected a cancelled run not to fire the check, got %v", err)
+	}
+}
+
+func TestCheckSkippedWhileDependencyUnhealthy(t *testing.T) {
+	for _, status := range []string{algochecks.StatusFailed, algochecks.StatusTimeout, algochecks.StatusSkipped} {
+		t.Run(status, func(t *testing.T) {
+			db := testCheck("DB", nil)
+			c := testCheck("API", nil)
+			c.DependsOn = []string{"DB"}
+			conf := &Config{Checks: []algochecks.Check{*db, *c}}
+			d := newTestDispatcher(t, conf, &recordingActioner{})
+			now := time.Now().UTC().Truncate(time.Second)
+
+			d.store.PutCheck(context.Background(), db, &algochecks.Output{Name: "DB", Status: status, Timestamp: now.Add(-time.Minute)})
+			out := runTestCheck(t, d, conf, c, &stepAlgorithmer{now: now.Add(time.Hour)})
+			if out.Status != algochecks.StatusSkipped || out.RC != -1 || !strings.Contains(out.CombinedOut, "DB") {
+				t.Fatalf("Expected the check to be skipped while DB is %s, got %+v", status, out)
+			}
+
+			d.store.PutCheck(context.Background(), db, &algochecks.Output{Name: "DB", Status: algochecks.StatusSuccess, Timestamp: now})
+			out = runTestCheck(t, d, conf, c, &stepAlgorithmer{now: now.Add(time.Hour)})
+			if out.Status != algochecks.StatusSuccess || !out.Timestamp.Equal(now.Add(time.Hour)) {
+				t.Fatalf("Expected the check to run once DB recovered, got %+v", out)
+			}
+		})
+	}
+}
@@ -20,4 +20,30 @@ var (
 		Name: "algomon_count_fail_total",
 		Help: "The total number of measurements that failed",
 	}, []string{"measurement"})
+
+	actionQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "algomon_action_queue_depth",
+		Help: "The number of actions waiting to be dispatched",
+	})
+
+	skippedTicks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "algomon_skipped_ticks_total",
+		Help: "The total number of scheduled check runs that were skipped, by reason",
+	}, []string{"measurement", "reason"})
+
+	checkQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "algomon_check_queue_depth",
+		Help: "The number of check runs waiting for an execution slot",
+	})
+
+	checkQueueWait = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "algomon_check_queue_wait_seconds",
+		Help:    "The time check runs waited for an execution slot",
+		Buckets: []float64{0.01, 0.1, 0.5, 1, 5, 10, 30, 60, 300},
+	})
+
+	remediationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "algomon_remediations_total",
+		Help: "The total number of remediation actions considered, by outcome",
+	}, []string{"check", "action", "outcome"})
 )
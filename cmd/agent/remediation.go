@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tchaudhry91/algomon/actions"
+	"github.com/tchaudhry91/algomon/store"
+)
+
+// remediate runs a remediation action within the limits of its policy. Every decision, including
+// the ones that did not run the action, is recorded in the remediation audit log. The decision is
+// recorded as running before the action is delivered, so concurrent workers see it in the audit log
+// and cannot exceed the limits together, without waiting on each other's deliveries.
+func (d *Dispatcher) remediate(ctx context.Context, checkName string, a *actions.ActionMeta, payload *actions.Payload) (actions.Output, error) {
+	policy := a.Remediation
+	now := time.Now().UTC()
+	record := store.RemediationRecord{
+		CheckName:  checkName,
+		ActionName: a.Name,
+		Actioner:   a.Actioner,
+		Action:     a.Action,
+		Timestamp:  now,
+	}
+	out := actions.Output{RC: -1, Timestamp: now}
+
+	lock := d.remediationLock(checkName, a.Name)
+	lock.Lock()
+	record.Outcome, record.Reason = d.remediationAllowed(ctx, checkName, a, now)
+	if record.Outcome == store.RemediationExecuted && !policy.DryRun {
+		record.Outcome = store.RemediationRunning
+		if _, storeErr := d.store.PutRemediation(context.Background(), &record); storeErr != nil {
+			record.Outcome = store.RemediationRateLimited
+			record.Reason = fmt.Sprintf("could not write audit log: %v", storeErr)
+		}
+	}
+	lock.Unlock()
+
+	var err error
+	switch record.Outcome {
+	case store.RemediationRunning:
+		var attempts int
+		out, attempts, err = d.deliver(ctx, a, payload)
+		record.Outcome = store.RemediationExecuted
+		if err != nil {
+			record.Outcome = store.RemediationFailed
+			record.Reason = fmt.Sprintf("failed after %d attempts", attempts)
+			record.Error = err.Error()
+		}
+	case store.RemediationExecuted:
+		record.Outcome = store.RemediationDryRun
+		record.Reason = "dry run enabled"
+		out.RC = 0
+		out.CombinedOut = fmt.Sprintf("Dry run: would have run action %s on actioner %s", a.Name, a.Actioner)
+	default:
+		out.CombinedOut = "Remediation skipped: " + record.Reason
+	}
+	record.Output = out
+
+	if _, storeErr := d.store.PutRemediation(context.Background(), &record); storeErr != nil {
+		d.logger.Error("Remediation Audit Storage Failed with error", "check", checkName, "name", a.Name, "err", storeErr)
+	}
+	remediationsTotal.WithLabelValues(checkName, a.Name, record.Outcome).Inc()
+	d.logger.Info("Remediation", "check", checkName, "name", a.Name, "outcome", record.Outcome, "reason", record.Reason)
+	return out, err
+}
+
+// remediationLock returns the lock deciding on runs of the remediation action of the check
+func (d *Dispatcher) remediationLock(checkName string, actionName string) *sync.Mutex {
+	d.remediationMu.Lock()
+	defer d.remediationMu.Unlock()
+	key := checkName + "/" + actionName
+	lock, ok := d.remediationLocks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		d.remediationLocks[key] = lock
+	}
+	return lock
+}
+
+// remediationAllowed checks the audit log against the rate limit and cooldown of the action,
+// returning the outcome and the reason it was decided on
+func (d *Dispatcher) remediationAllowed(ctx context.Context, checkName string, a *actions.ActionMeta, now time.Time) (string, string) {
+	policy := a.Remediation
+	window := max(policy.Window.Duration, policy.Cooldown.Duration)
+	if window <= 0 {
+		return store.RemediationExecuted, ""
+	}
+	records, err := d.store.GetRemediations(ctx, checkName, a.Name, now.Add(-window))
+	if err != nil {
+		// Without the audit log the limits cannot be enforced, so err on the side of not acting
+		return store.RemediationRateLimited, fmt.Sprintf("could not read audit log: %v", err)
+	}
+	executions := 0
+	for _, r := range records {
+		if r.Outcome != store.RemediationRunning && r.Outcome != store.RemediationExecuted && r.Outcome != store.RemediationFailed {
+			continue
+		}
+		if policy.Cooldown.Duration > 0 && now.Sub(r.Timestamp) < policy.Cooldown.Duration {
+			return store.RemediationCoolingDown, fmt.Sprintf("last run at %s, cooldown %s", r.Timestamp.Format(time.RFC3339), policy.Cooldown.Duration)
+		}
+		if policy.Window.Duration > 0 && now.Sub(r.Timestamp) < policy.Window.Duration {
+			executions++
+		}
+	}
+	if policy.MaxExecutions > 0 && policy.Window.Duration > 0 && executions >= policy.MaxExecutions {
+		return store.RemediationRateLimited, fmt.Sprintf("%d runs in the last %s", executions, policy.Window.Duration)
+	}
+	return store.RemediationExecuted, ""
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/tchaudhry91/algomon/actions"
+	"github.com/tchaudhry91/algomon/algochecks"
+	"github.com/tchaudhry91/algomon/measure"
+	"github.com/tchaudhry91/algomon/store"
+)
+
+func remediationAction(maxExecutions int) actions.ActionMeta {
+	return actions.ActionMeta{
+		Name:     "Restart",
+		Actioner: "test",
+		Remediation: &actions.RemediationPolicy{
+			MaxExecutions: maxExecutions,
+			Window:        measure.Duration{Duration: time.Hour},
+		},
+	}
+}
+
+func TestRemediateLimitsConcurrentRuns(t *testing.T) {
+	recorder := &recordingActioner{delay: 50 * time.Millisecond}
+	d := newTestDispatcher(t, &Config{}, recorder)
+	c := testCheck("API", nil)
+	action := remediationAction(1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.remediate(context.Background(), c.Name, &action, testJobPayload(c, actions.StateFiring))
+		}()
+	}
+	wg.Wait()
+	if len(recorder.received()) != 1 {
+		t.Fatalf("Expected a single remediation run, got %d", len(recorder.received()))
+	}
+	records, _ := d.store.GetRemediations(context.Background(), c.Name, action.Name, time.Now().Add(-time.Minute))
+	outcomes := map[string]int{}
+	for _, r := range records {
+		outcomes[r.Outcome]++
+	}
+	if len(records) != 3 || outcomes[store.RemediationExecuted] != 1 || outcomes[store.RemediationRateLimited] != 2 {
+		t.Fatalf("Expected 1 executed and 2 rate limited records, got %v", outcomes)
+	}
+}
+
+func TestRemediateDoesNotWaitOnOtherChecks(t *testing.T) {
+	recorder := &recordingActioner{delay: 500 * time.Millisecond}
+	d := newTestDispatcher(t, &Config{}, recorder)
+	slow := testCheck("Slow", nil)
+	fast := testCheck("Fast", nil)
+	action := remediationAction(0)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.remediate(context.Background(), slow.Name, &action, testJobPayload(slow, actions.StateFiring))
+	}()
+	defer func() { <-done }()
+	time.Sleep(50 * time.Millisecond)
+	fastAction := remediationAction(0)
+	fastAction.Remediation.DryRun = true
+	start := time.Now()
+	if _, err := d.remediate(context.Background(), fast.Name, &fastAction, testJobPayload(fast, actions.StateFiring)); err != nil {
+		t.Fatalf("Remediation failed:%v", err)
+	}
+	if waited := time.Since(start); waited > 250*time.Millisecond {
+		t.Fatalf("Expected the remediation not to wait on the one of another check, waited %s", waited)
+	}
+}
+
+func TestRemediationsAreNotPersisted(t *testing.T) {
+	d := newTestDispatcher(t, &Config{}, &recordingActioner{})
+	c := testCheck("API", nil)
+	d.Shutdown(context.Background())
+	if keys := d.Dispatch(c, []actions.ActionMeta{remediationAction(0)}, testJobPayload(c, actions.StateFiring)); len(keys) != 0 {
+		t.Fatalf("Expected the remediation not to be queued, got %v", keys)
+	}
+	if count := failedActionCount(t, d); count != 0 {
+		t.Fatalf("Expected the remediation not to be persisted, got %d failed actions", count)
+	}
+}
+
+func TestReplayDropsRemediations(t *testing.T) {
+	recorder := &recordingActioner{}
+	d := newTestDispatcher(t, &Config{}, recorder)
+	c := testCheck("API", nil)
+	id, err := d.store.PutFailedAction(context.Background(), &store.FailedAction{
+		CheckName: c.Name,
+		Action:    remediationAction(0),
+		Payload:   testJobPayload(c, actions.StateFiring),
+		Error:     "action queue full",
+	})
+	if err != nil {
+		t.Fatalf("Could not store failed action:%v", err)
+	}
+	if _, err := d.Replay(context.Background(), id); !errors.Is(err, ErrRemediationReplay) {
+		t.Fatalf("Expected the remediation replay to be refused, got %v", err)
+	}
+	if len(recorder.received()) != 0 || failedActionCount(t, d) != 0 {
+		t.Fatalf("Expected the remediation to be dropped without running")
+	}
+}
+
+func TestRecoveryDoesNotRemediate(t *testing.T) {
+	resolve := true
+	c := testCheck("API", nil)
+	c.AlgorithmerType = "step"
+	restart := remediationAction(0)
+	restart.SendResolved = &resolve
+	c.Actions = []actions.ActionMeta{restart, {Name: "Notify", Actioner: "test", SendResolved: &resolve}}
+	conf := &Config{Checks: []algochecks.Check{*c}}
+	d := newTestDispatcher(t, conf, &recordingActioner{})
+
+	algorithmer := &stepAlgorithmer{failing: true, now: time.Now().UTC().Add(-time.Minute)}
+	run := func() []string {
+		runCheck(context.Background(), c, conf, log.Default(), d.store, map[string]algochecks.Algorithmer{"step": algorithmer}, d, true)
+		status, _ := d.store.GetCheckStatus(context.Background(), c.Name)
+		return status.ActionKeys
+	}
+	if keys := run(); len(keys) != 2 {
+		t.Fatalf("Expected both actions on failure, got %v", keys)
+	}
+	algorithmer.failing, algorithmer.now = false, time.Now().UTC()
+	if keys := run(); len(keys) != 1 || keys[0] != store.ActionKey(c.Name, "Notify", algorithmer.now) {
+		t.Fatalf("Expected only the notification on recovery, got %v", keys)
+	}
+}
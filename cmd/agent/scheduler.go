@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	log "github.com/charmbracelet/log"
+)
+
+var OverlapSkip = "skip"
+var OverlapQueue = "queue"
+
+var defaultMaxJitter = 10 * time.Second
+
+// Job is a recurring task run by the Scheduler
+type Job struct {
+	Name string
+	// Next returns the time of the run following the one scheduled at the given time
+	Next func(time.Time) time.Time
+	// Jitter delays the first scheduled run by a random duration up to its value
+	Jitter time.Duration
+	// Immediate runs the job once as soon as it is scheduled
+	Immediate bool
+	// Overlap decides what happens to a run that is due while the previous one is still going,
+	// OverlapSkip drops it and OverlapQueue runs it once the previous one is done
+	Overlap string
+	Run     func(ctx context.Context)
+}
+
+type scheduledJob struct {
+	Job
+	stop    chan struct{}
+	removed bool
+	next    time.Time
+}
+
+// jobRun is the run in progress of a job. It is kept by name, so a job that is rescheduled while
+// running does not start a run alongside the one of its previous definition.
+type jobRun struct {
+	queued bool
+	// cancel cancels the run
+	cancel context.CancelFunc
+}
+
+// Scheduler runs jobs on their schedules, never running two runs of the same job at once.
+// It owns the contexts of the runs, cancelling them when a job is removed or on Stop.
+type Scheduler struct {
+	logger *log.Logger
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	jobs map[string]*scheduledJob
+	runs map[string]*jobRun
+}
+
+func NewScheduler(logger *log.Logger) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
+		jobs:   map[string]*scheduledJob{},
+		runs:   map[string]*jobRun{},
+	}
+}
+
+// Schedule starts running the job, replacing any job of the same name. A run of the replaced job
+// that is still in progress is left to complete, and counts as in progress for the new one.
+func (s *Scheduler) Schedule(job Job) {
+	if job.Overlap == "" {
+		job.Overlap = OverlapSkip
+	}
+	j := &scheduledJob{Job: job, stop: make(chan struct{})}
+	s.mu.Lock()
+	if old, ok := s.jobs[job.Name]; ok {
+		old.removed = true
+		close(old.stop)
+	}
+	s.jobs[job.Name] = j
+	s.mu.Unlock()
+	s.wg.Add(1)
+	go s.loop(j)
+}
+
+// Unschedule stops running the named job, cancelling its run in progress
+func (s *Scheduler) Unschedule(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[name]
+	if !ok {
+		return
+	}
+	delete(s.jobs, name)
+	j.removed = true
+	close(j.stop)
+	if r, ok := s.runs[name]; ok {
+		r.queued = false
+		r.cancel()
+	}
+}
+
+// Stop cancels all runs in progress and waits for them to return, or for ctx to expire
+func (s *Scheduler) Stop(ctx context.Context) {
+	s.mu.Lock()
+	for name, j := range s.jobs {
+		s.logger.Info("Cancelling", "name", name)
+		delete(s.jobs, name)
+		j.removed = true
+		close(j.stop)
+	}
+	s.mu.Unlock()
+	s.cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.logger.Warn("Runs did not return in time")
+	}
+}
+
+// NextRun returns when the named job is next due to run
+func (s *Scheduler) NextRun(name string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[name]
+	if !ok || j.next.IsZero() {
+		return time.Time{}, false
+	}
+	return j.next, true
+}
+
+func (s *Scheduler) loop(j *scheduledJob) {
+	defer s.wg.Done()
+	// The jitter also delays immediate runs, so the jobs scheduled together do not all run at once
+	var jitter time.Duration
+	if j.Jitter > 0 {
+		jitter = time.Duration(rand.Int63n(int64(j.Jitter)))
+	}
+	start := time.Now().Add(jitter)
+	next := j.Next(start)
+	if j.Immediate {
+		next = start
+	}
+	for {
+		if next.IsZero() {
+			s.logger.Warn("Job has no future runs", "name", j.Name)
+			return
+		}
+		s.mu.Lock()
+		j.next = next
+		s.mu.Unlock()
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-j.stop:
+			timer.Stop()
+			return
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.trigger(j)
+		var missed int
+		next, missed = followingRun(j.Next, next, time.Now())
+		if missed > 0 {
+			skippedTicks.WithLabelValues(j.Name, "missed").Add(float64(missed))
+		}
+	}
+}
+
+// followingRun returns the run of the schedule following the one at scheduled that is still to
+// come at now, along with the number of runs that were due in between. Runs that were due while
+// the agent could not keep up are not caught up on.
+func followingRun(next func(time.Time) time.Time, scheduled time.Time, now time.Time) (time.Time, int) {
+	missed := 0
+	following := next(scheduled)
+	for !following.IsZero() && !following.After(now) {
+		missed++
+		following = next(following)
+	}
+	return following, missed
+}
+
+// trigger starts a run of the job, unless one is in progress
+func (s *Scheduler) trigger(j *scheduledJob) {
+	s.mu.Lock()
+	if j.removed {
+		s.mu.Unlock()
+		return
+	}
+	if r, ok := s.runs[j.Name]; ok {
+		if j.Overlap == OverlapQueue && !r.queued {
+			r.queued = true
+			s.mu.Unlock()
+			s.logger.Warn("Previous run still in progress, queueing run", "name", j.Name)
+			return
+		}
+		s.mu.Unlock()
+		skippedTicks.WithLabelValues(j.Name, "overlap").Inc()
+		s.logger.Warn("Previous run still in progress, skipping run", "name", j.Name)
+		return
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	r := &jobRun{cancel: cancel}
+	s.runs[j.Name] = r
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		run := j.Run
+		for {
+			run(ctx)
+			r.cancel()
+			s.mu.Lock()
+			// A queued run uses the current definition of the job, which may have been rescheduled
+			if current, ok := s.jobs[j.Name]; ok && r.queued {
+				r.queued = false
+				ctx, r.cancel = context.WithCancel(s.ctx)
+				run = current.Run
+				s.mu.Unlock()
+				continue
+			}
+			delete(s.runs, j.Name)
+			s.mu.Unlock()
+			return
+		}
+	}()
+}
+
+// everyInterval schedules runs a fixed interval apart
+func everyInterval(interval time.Duration) func(time.Time) time.Time {
+	return func(t time.Time) time.Time {
+		return t.Add(interval)
+	}
+}
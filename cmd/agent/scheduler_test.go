@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// blockingRun counts its runs, which block until released
+type blockingRun struct {
+	mu      sync.Mutex
+	started int
+	active  int
+	overlap bool
+	release chan struct{}
+}
+
+func newBlockingRun() *blockingRun {
+	return &blockingRun{release: make(chan struct{})}
+}
+
+func (b *blockingRun) Run(ctx context.Context) {
+	b.mu.Lock()
+	b.started++
+	b.active++
+	if b.active > 1 {
+		b.overlap = true
+	}
+	b.mu.Unlock()
+	select {
+	case <-b.release:
+	case <-ctx.Done():
+	}
+	b.mu.Lock()
+	b.active--
+	b.mu.Unlock()
+}
+
+func (b *blockingRun) counts() (started int, active int, overlap bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.started, b.active, b.overlap
+}
+
+// manualJob schedules the job without any scheduled runs, returning it to be triggered by hand
+func manualJob(s *Scheduler, job Job) *scheduledJob {
+	job.Next = func(time.Time) time.Time { return time.Time{} }
+	s.Schedule(job)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jobs[job.Name]
+}
+
+func newTestScheduler(t *testing.T) *Scheduler {
+	s := NewScheduler(log.Default())
+	t.Cleanup(func() { s.Stop(context.Background()) })
+	return s
+}
+
+func TestSchedulerOverlap(t *testing.T) {
+	tests := []struct {
+		overlap  string
+		triggers int
+		expected int
+	}{
+		{OverlapSkip, 3, 1},
+		{OverlapQueue, 3, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.overlap, func(t *testing.T) {
+			s := newTestScheduler(t)
+			b := newBlockingRun()
+			j := manualJob(s, Job{Name: "check", Overlap: tt.overlap, Run: b.Run})
+			for i := 0; i < tt.triggers; i++ {
+				s.trigger(j)
+			}
+			for i := 0; i < tt.expected; i++ {
+				waitFor(t, "run to start", func() bool { started, _, _ := b.counts(); return started == i+1 })
+				b.release <- struct{}{}
+			}
+			waitFor(t, "runs to end", func() bool { _, active, _ := b.counts(); return active == 0 })
+			if started, _, overlap := b.counts(); started != tt.expected || overlap {
+				t.Fatalf("Expected %d runs without overlap, got %d runs, overlap %v", tt.expected, started, overlap)
+			}
+		})
+	}
+}
+
+func TestSchedulerRescheduleWaitsForRun(t *testing.T) {
+	s := newTestScheduler(t)
+	b := newBlockingRun()
+	old := manualJob(s, Job{Name: "check", Overlap: OverlapQueue, Run: b.Run})
+	s.trigger(old)
+	waitFor(t, "run to start", func() bool { started, _, _ := b.counts(); return started == 1 })
+
+	replaced := newBlockingRun()
+	j := manualJob(s, Job{Name: "check", Overlap: OverlapQueue, Run: replaced.Run})
+	s.trigger(j)
+	if started, _, _ := replaced.counts(); started != 0 {
+		t.Fatalf("Expected the rescheduled job not to run alongside the previous run")
+	}
+	b.release <- struct{}{}
+	waitFor(t, "queued run of the new definition", func() bool { started, _, _ := replaced.counts(); return started == 1 })
+	replaced.release <- struct{}{}
+	if started, _, _ := b.counts(); started != 1 {
+		t.Fatalf("Expected the queued run to use the new definition, the old one ran %d times", started)
+	}
+}
+
+func TestSchedulerUnscheduleCancelsRun(t *testing.T) {
+	s := newTestScheduler(t)
+	b := newBlockingRun()
+	j := manualJob(s, Job{Name: "check", Overlap: OverlapQueue, Run: b.Run})
+	s.trigger(j)
+	s.trigger(j)
+	waitFor(t, "run to start", func() bool { started, _, _ := b.counts(); return started == 1 })
+	s.Unschedule("check")
+	waitFor(t, "run to be cancelled", func() bool { _, active, _ := b.counts(); return active == 0 })
+	time.Sleep(20 * time.Millisecond)
+	if started, _, _ := b.counts(); started != 1 {
+		t.Fatalf("Expected the queued run to be dropped, got %d runs", started)
+	}
+}
+
+func TestFollowingRun(t *testing.T) {
+	scheduled := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		now      time.Time
+		expected time.Time
+		missed   int
+	}{
+		{scheduled.Add(time.Second), scheduled.Add(time.Minute), 0},
+		{scheduled.Add(time.Minute), scheduled.Add(2 * time.Minute), 1},
+		{scheduled.Add(3*time.Minute + time.Second), scheduled.Add(4 * time.Minute), 3},
+	}
+	for _, tt := range tests {
+		following, missed := followingRun(everyInterval(time.Minute), scheduled, tt.now)
+		if !following.Equal(tt.expected) || missed != tt.missed {
+			t.Fatalf("Expected %s with %d missed at %s, got %s with %d", tt.expected, tt.missed, tt.now, following, missed)
+		}
+	}
+}
+
+func TestSchedulerJittersImmediateRuns(t *testing.T) {
+	s := newTestScheduler(t)
+	b := newBlockingRun()
+	start := time.Now()
+	s.Schedule(Job{Name: "check", Next: everyInterval(time.Hour), Jitter: time.Hour, Immediate: true, Run: b.Run})
+	waitFor(t, "first run to be scheduled", func() bool { _, ok := s.NextRun("check"); return ok })
+	next, _ := s.NextRun("check")
+	if next.Before(start) || next.After(start.Add(time.Hour)) {
+		t.Fatalf("Expected the immediate run within the jitter, got %s", next.Sub(start))
+	}
+	if started, _, _ := b.counts(); started != 0 && next.Sub(start) > 10*time.Millisecond {
+		t.Fatalf("Expected the immediate run to wait for its jitter")
+	}
+}
+
+func TestSchedulerRunsImmediately(t *testing.T) {
+	s := newTestScheduler(t)
+	b := newBlockingRun()
+	s.Schedule(Job{Name: "check", Next: everyInterval(time.Hour), Immediate: true, Run: b.Run})
+	waitFor(t, "immediate run", func() bool { started, _, _ := b.counts(); return started == 1 })
+	b.release <- struct{}{}
+	waitFor(t, "next run an interval later", func() bool {
+		next, _ := s.NextRun("check")
+		return time.Until(next) > 59*time.Minute
+	})
+}
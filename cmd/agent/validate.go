@@ -0,0 +1,346 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	log "github.com/charmbracelet/log"
+	"github.com/tchaudhry91/algomon/actions"
+	"github.com/tchaudhry91/algomon/algochecks"
+)
+
+// validateCommand checks a config without running it, printing every problem found. It is meant
+// for CI, exiting non-zero when the config is invalid.
+func validateCommand(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	configF := flags.String("c", "algomon.json", "config file, directory or glob of files to validate")
+	flags.Parse(args)
+
+	problems := []error{}
+	conf, err := loadConfig(*configF)
+	if err != nil {
+		problems = append(problems, unjoin(err)...)
+	}
+	if conf != nil {
+		if err := validateConfig(conf, true); err != nil {
+			problems = append(problems, unjoin(err)...)
+		}
+	}
+	if len(problems) == 0 {
+		fmt.Printf("%s is valid\n", *configF)
+		return 0
+	}
+	for _, p := range problems {
+		fmt.Fprintln(os.Stderr, p)
+	}
+	fmt.Fprintf(os.Stderr, "%s is invalid, %d problems found\n", *configF, len(problems))
+	return 1
+}
+
+// unjoin flattens errors joined with errors.Join
+func unjoin(err error) []error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	errs := []error{}
+	for _, e := range joined.Unwrap() {
+		errs = append(errs, unjoin(e)...)
+	}
+	return errs
+}
+
+// configValidator collects the problems found in a config
+type configValidator struct {
+	errs []error
+	// checkFiles checks the filesystem as well, see validateConfig
+	checkFiles bool
+}
+
+func (v *configValidator) errorf(format string, args ...any) {
+	v.errs = append(v.errs, fmt.Errorf(format, args...))
+}
+
+// validateConfig checks the whole config, returning all the problems found joined into one error.
+// With checkFiles, it also checks that the working directory and python scripts exist. The agent
+// leaves that to the runs needing them, so a missing script only fails its own check.
+func validateConfig(conf *Config, checkFiles bool) error {
+	v := &configValidator{checkFiles: checkFiles}
+
+	if conf.MaxConcurrentChecks < 0 {
+		v.errorf("max_concurrent_checks cannot be negative")
+	}
+	if conf.ActionWorkers < 0 || conf.ActionQueueSize < 0 || conf.FailedActionMaxCount < 0 {
+		v.errorf("action_workers, action_queue_size and failed_action_max_count cannot be negative")
+	}
+	if conf.FailedActionRetryInterval.Duration < 0 || conf.FailedActionMaxAge.Duration < 0 ||
+		conf.ActionDrainTimeout.Duration < 0 || conf.CheckTimeout.Duration < 0 ||
+		(conf.ScheduleJitter != nil && conf.ScheduleJitter.Duration < 0) {
+		v.errorf("failed_action_retry_interval, failed_action_max_age, action_drain_timeout, check_timeout and schedule_jitter cannot be negative")
+	}
+	if conf.BaseWorkingDir != "" && v.checkFiles {
+		if info, err := os.Stat(conf.BaseWorkingDir); err != nil || !info.IsDir() {
+			v.errorf("base_working_dir %q is not a directory", conf.BaseWorkingDir)
+		}
+	}
+	if conf.ExternalURL != "" {
+		if err := checkURL(conf.ExternalURL); err != nil {
+			v.errorf("external_url %v", err)
+		}
+	}
+
+	datasources := make(map[string]struct{})
+	for _, d := range conf.Datasources {
+		if d.Name == "" {
+			v.errorf("datasource name cannot be empty")
+			continue
+		}
+		if _, ok := datasources[d.Name]; ok {
+			v.errorf("datasource %q is defined more than once", d.Name)
+		}
+		datasources[d.Name] = struct{}{}
+		if err := checkURL(d.URL); err != nil {
+			v.errorf("datasource %q url %v", d.Name, err)
+		}
+	}
+
+	algorithmers := make(map[string]algochecks.AlgorithmerMeta)
+	for _, a := range conf.Algorithmers {
+		if a.Type == "" {
+			v.errorf("algorithmer type cannot be empty")
+			continue
+		}
+		if _, ok := algorithmers[a.Type]; ok {
+			v.errorf("algorithmer %q is defined more than once", a.Type)
+		}
+		algorithmers[a.Type] = a
+		if algochecks.Build(a, log.Default()) == nil {
+			v.errorf("algorithmer %q is not a known algorithmer type", a.Type)
+		}
+		if a.MaxConcurrent < 0 {
+			v.errorf("algorithmer %q max_concurrent cannot be negative", a.Type)
+		}
+	}
+
+	templates, err := actions.NewTemplates(conf.Templates, conf.ExternalURL)
+	if err != nil {
+		v.errorf("templates %v", err)
+	}
+
+	actioners := make(map[string]actions.ActionerMeta)
+	for _, a := range conf.Actioners {
+		if a.Type == "" {
+			v.errorf("actioner type cannot be empty")
+			continue
+		}
+		if _, ok := actioners[a.Type]; ok {
+			v.errorf("actioner %q is defined more than once", a.Type)
+		}
+		actioners[a.Type] = a
+		if actions.Build(a, templates, log.Default()) == nil {
+			v.errorf("actioner %q is not a known actioner type", a.Type)
+		}
+		v.params(fmt.Sprintf("actioner %q", a.Type), templates, a.Params)
+		if _, ok := a.Params["retries"]; ok {
+			v.errorf("actioner %q param retries is not supported, set retries on its actions", a.Type)
+		}
+	}
+
+	receivers := make(map[string]struct{})
+	for _, r := range conf.Receivers {
+		if r.Name == "" {
+			v.errorf("receiver name cannot be empty")
+			continue
+		}
+		if _, ok := receivers[r.Name]; ok {
+			v.errorf("receiver %q is defined more than once", r.Name)
+		}
+		receivers[r.Name] = struct{}{}
+		v.actions(fmt.Sprintf("receiver %q", r.Name), r.Actions, actioners, templates)
+	}
+	for i, r := range conf.Routes {
+		if _, ok := receivers[r.Receiver]; !ok {
+			v.errorf("route %d uses undefined receiver %q", i, r.Receiver)
+		}
+	}
+
+	checks := make(map[string]struct{})
+	for _, c := range conf.Checks {
+		if c.Name == "" {
+			v.errorf("check name cannot be empty")
+			continue
+		}
+		if _, ok := checks[c.Name]; ok {
+			v.errorf("check %q is defined more than once", c.Name)
+		}
+		checks[c.Name] = struct{}{}
+		v.check(conf, &c, datasources, algorithmers, actioners, templates)
+	}
+
+	if _, err := algochecks.DependencyOrder(conf.Checks); err != nil {
+		v.errorf("%v", err)
+	}
+
+	for i, r := range conf.InhibitRules {
+		if err := r.Source.validate(checks); err != nil {
+			v.errorf("inhibit rule %d source %v", i, err)
+		}
+		if err := r.Target.validate(checks); err != nil {
+			v.errorf("inhibit rule %d target %v", i, err)
+		}
+		if r.Wait != nil && r.Wait.Duration < 0 {
+			v.errorf("inhibit rule %d wait cannot be negative", i)
+		}
+	}
+	return errors.Join(v.errs...)
+}
+
+func (v *configValidator) check(conf *Config, c *algochecks.Check, datasources map[string]struct{}, algorithmers map[string]algochecks.AlgorithmerMeta, actioners map[string]actions.ActionerMeta, templates *actions.Templates) {
+	if c.Cron != "" {
+		if c.Interval.Duration != 0 {
+			v.errorf("check %q cannot have both an interval and a cron schedule", c.Name)
+		}
+		if _, err := c.CronSchedule(); err != nil {
+			v.errorf("check %q has invalid cron schedule: %v", c.Name, err)
+		}
+	} else if c.Interval.Duration <= 0 {
+		v.errorf("check %q interval must be positive", c.Name)
+	}
+	if c.Timeout.Duration < 0 {
+		v.errorf("check %q timeout cannot be negative", c.Name)
+	}
+	if c.Overlap != "" && c.Overlap != OverlapSkip && c.Overlap != OverlapQueue {
+		v.errorf("check %q has invalid overlap %q, expected %q or %q", c.Name, c.Overlap, OverlapSkip, OverlapQueue)
+	}
+	if c.ActiveTimes != nil {
+		if err := c.ActiveTimes.Validate(); err != nil {
+			v.errorf("check %q has invalid active_times: %v", c.Name, err)
+		}
+	}
+
+	if algorithmer, ok := algorithmers[c.AlgorithmerType]; !ok {
+		v.errorf("check %q uses undefined algorithmer type %q", c.Name, c.AlgorithmerType)
+	} else if algorithmer.Type == "python" && v.checkFiles {
+		if err := checkScript(algorithmer.Params["directory"], c.Algorithm); err != nil {
+			v.errorf("check %q algorithm %v", c.Name, err)
+		}
+	}
+
+	inputs := make(map[string]struct{})
+	for _, i := range c.Inputs {
+		if i.Name == "" {
+			v.errorf("check %q input name cannot be empty", c.Name)
+		} else if _, ok := inputs[i.Name]; ok {
+			v.errorf("check %q input %q is defined more than once", c.Name, i.Name)
+		}
+		inputs[i.Name] = struct{}{}
+		if _, ok := datasources[i.Datasource]; !ok {
+			v.errorf("check %q uses undefined datasource %q", c.Name, i.Datasource)
+		}
+		if strings.TrimSpace(i.Query) == "" {
+			v.errorf("check %q input %q has an empty query", c.Name, i.Name)
+		}
+	}
+
+	v.actions(fmt.Sprintf("check %q", c.Name), c.Actions, actioners, templates)
+
+	// Escalation steps may refer to the actions routed to the check
+	routed := *c
+	routed.Actions = routedActions(conf, c)
+	if err := routed.ValidateEscalation(); err != nil {
+		v.errorf("check %q has invalid escalation: %v", c.Name, err)
+	}
+}
+
+func (v *configValidator) actions(prefix string, list []actions.ActionMeta, actioners map[string]actions.ActionerMeta, templates *actions.Templates) {
+	names := make(map[string]struct{})
+	for _, a := range list {
+		if a.Name == "" {
+			v.errorf("%s action name cannot be empty", prefix)
+		} else if _, ok := names[a.Name]; ok {
+			v.errorf("%s action %q is defined more than once", prefix, a.Name)
+		}
+		names[a.Name] = struct{}{}
+		prefix := fmt.Sprintf("%s action %q", prefix, a.Name)
+
+		if actioner, ok := actioners[a.Actioner]; !ok {
+			v.errorf("%s uses undefined actioner type %q", prefix, a.Actioner)
+		} else {
+			if actioner.Type == "python" && v.checkFiles {
+				if err := checkScript(actioner.Params["directory"], a.Action); err != nil {
+					v.errorf("%s %v", prefix, err)
+				}
+			}
+			if a.Group != nil && !actions.SupportsGrouping(actioner.Type) {
+				v.errorf("%s cannot group notifications of the %s actioner, only teams, slack and mattermost", prefix, actioner.Type)
+			}
+		}
+		v.params(prefix, templates, a.Params)
+		if a.Retries < 0 || a.RetryBackoff.Duration < 0 {
+			v.errorf("%s retries and retry_backoff cannot be negative", prefix)
+		}
+		if a.Remediation != nil {
+			if err := a.Remediation.Validate(); err != nil {
+				v.errorf("%s has invalid remediation: %v", prefix, err)
+			}
+			if a.SendResolved != nil && *a.SendResolved {
+				v.errorf("%s cannot send_resolved a remediation", prefix)
+			}
+		}
+		if a.Group != nil {
+			if a.Remediation != nil {
+				v.errorf("%s cannot group a remediation", prefix)
+			}
+			if err := a.Group.Validate(); err != nil {
+				v.errorf("%s has invalid group: %v", prefix, err)
+			}
+		}
+	}
+}
+
+// params checks that the templates referenced by _template params are defined, and that inline
+// templates parse
+func (v *configValidator) params(prefix string, templates *actions.Templates, params map[string]string) {
+	for k, val := range params {
+		if strings.HasSuffix(k, "_template") {
+			if !templates.Has(val) {
+				v.errorf("%s references undefined template %q in %q", prefix, val, k)
+			}
+			continue
+		}
+		if strings.Contains(val, "{{") {
+			if err := templates.Parse(k, val); err != nil {
+				v.errorf("%s param %q is not a valid template: %v", prefix, k, err)
+			}
+		}
+	}
+}
+
+// checkURL ensures a URL is absolute http(s)
+func checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("is invalid: %v", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q must be an absolute http or https URL", raw)
+	}
+	return nil
+}
+
+// checkScript ensures the python script run for name exists in directory
+func checkScript(directory string, name string) error {
+	if name == "" {
+		return fmt.Errorf("name cannot be empty")
+	}
+	script := path.Join(directory, name+".py")
+	if _, err := os.Stat(script); err != nil {
+		return fmt.Errorf("script %s not found", script)
+	}
+	return nil
+}
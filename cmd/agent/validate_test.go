@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tchaudhry91/algomon/actions"
+	"github.com/tchaudhry91/algomon/algochecks"
+	"github.com/tchaudhry91/algomon/measure"
+)
+
+// validConfig returns a config passing validation, with its scripts in dir
+func validConfig(dir string) *Config {
+	return &Config{
+		BaseWorkingDir: dir,
+		Datasources:    []Datasource{{Name: "prom", URL: "http://localhost:9090"}},
+		Algorithmers:   []algochecks.AlgorithmerMeta{{Type: "python", Params: map[string]string{"directory": dir}}},
+		Actioners: []actions.ActionerMeta{
+			{Type: "python", Params: map[string]string{"directory": dir}},
+			{Type: "slack", Params: map[string]string{"url": "http://localhost/hook"}},
+		},
+		Checks: []algochecks.Check{{
+			Name:            "API",
+			Interval:        measure.Duration{Duration: time.Minute},
+			AlgorithmerType: "python",
+			Algorithm:       "algo",
+			Inputs:          []measure.Measurement{{Name: "up", Datasource: "prom", Query: "up"}},
+			Actions: []actions.ActionMeta{
+				{Name: "Fix", Actioner: "python", Action: "fix"},
+				{Name: "Notify", Actioner: "slack"},
+			},
+		}},
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	dir := t.TempDir()
+	for _, script := range []string{"algo.py", "fix.py"} {
+		if err := os.WriteFile(filepath.Join(dir, script), nil, 0644); err != nil {
+			t.Fatalf("Could not write script:%v", err)
+		}
+	}
+	tests := []struct {
+		name     string
+		modify   func(c *Config)
+		expected string
+	}{
+		{"valid", func(c *Config) {}, ""},
+		{"duplicate datasource", func(c *Config) { c.Datasources = append(c.Datasources, c.Datasources[0]) }, `datasource "prom" is defined more than once`},
+		{"duplicate check", func(c *Config) { c.Checks = append(c.Checks, c.Checks[0]) }, `check "API" is defined more than once`},
+		{"duplicate action", func(c *Config) { c.Checks[0].Actions[1].Name = "Fix" }, `check "API" action "Fix" is defined more than once`},
+		{"relative datasource url", func(c *Config) { c.Datasources[0].URL = "localhost:9090" }, `datasource "prom" url "localhost:9090" must be an absolute http or https URL`},
+		{"datasource url scheme", func(c *Config) { c.Datasources[0].URL = "ftp://localhost" }, `must be an absolute http or https URL`},
+		{"external url", func(c *Config) { c.ExternalURL = "algomon" }, `external_url "algomon" must be an absolute http or https URL`},
+		{"zero interval", func(c *Config) { c.Checks[0].Interval.Duration = 0 }, `check "API" interval must be positive`},
+		{"negative interval", func(c *Config) { c.Checks[0].Interval.Duration = -time.Minute }, `check "API" interval must be positive`},
+		{"cron", func(c *Config) { c.Checks[0].Interval.Duration = 0; c.Checks[0].Cron = "*/5 * * * *" }, ""},
+		{"invalid cron", func(c *Config) { c.Checks[0].Interval.Duration = 0; c.Checks[0].Cron = "every minute" }, `check "API" has invalid cron schedule`},
+		{"cron and interval", func(c *Config) { c.Checks[0].Cron = "*/5 * * * *" }, `check "API" cannot have both an interval and a cron schedule`},
+		{"negative timeout", func(c *Config) { c.CheckTimeout.Duration = -time.Second }, `check_timeout and schedule_jitter cannot be negative`},
+		{"invalid overlap", func(c *Config) { c.Checks[0].Overlap = "wait" }, `check "API" has invalid overlap "wait"`},
+		{"undefined datasource", func(c *Config) { c.Checks[0].Inputs[0].Datasource = "thanos" }, `check "API" uses undefined datasource "thanos"`},
+		{"empty query", func(c *Config) { c.Checks[0].Inputs[0].Query = " " }, `check "API" input "up" has an empty query`},
+		{"unknown algorithmer", func(c *Config) { c.Algorithmers[0].Type = "lua"; c.Checks[0].AlgorithmerType = "lua" }, `algorithmer "lua" is not a known algorithmer type`},
+		{"unknown actioner", func(c *Config) { c.Actioners[1].Type = "irc"; c.Checks[0].Actions[1].Actioner = "irc" }, `actioner "irc" is not a known actioner type`},
+		{"undefined template", func(c *Config) { c.Checks[0].Actions[1].Params = map[string]string{"body_template": "missing"} }, `references undefined template "missing" in "body_template"`},
+		{"invalid inline template", func(c *Config) { c.Checks[0].Actions[1].Params = map[string]string{"title": "{{ .Check.Name "} }, `param "title" is not a valid template`},
+		{"group non chat action", func(c *Config) { c.Checks[0].Actions[0].Group = &actions.GroupPolicy{By: []string{"check"}} }, `cannot group notifications of the python actioner`},
+		{"group chat action", func(c *Config) { c.Checks[0].Actions[1].Group = &actions.GroupPolicy{By: []string{"check"}} }, ""},
+		{"undefined dependency", func(c *Config) { c.Checks[0].DependsOn = []string{"DB"} }, `check "API" depends on undefined check "DB"`},
+		{"actioner retries", func(c *Config) { c.Actioners[1].Params["retries"] = "2" }, `actioner "slack" param retries is not supported, set retries on its actions`},
+		{"resolved remediation", func(c *Config) {
+			resolve := true
+			c.Checks[0].Actions[0].Remediation = &actions.RemediationPolicy{}
+			c.Checks[0].Actions[0].SendResolved = &resolve
+		}, `check "API" action "Fix" cannot send_resolved a remediation`},
+		{"inhibit rule wait", func(c *Config) {
+			c.InhibitRules = []InhibitRule{{Source: CheckSelector{Checks: []string{"API"}}, Target: CheckSelector{Match: map[string]string{"team": "db"}}, Wait: &measure.Duration{Duration: -time.Second}}}
+		}, `inhibit rule 0 wait cannot be negative`},
+		{"undefined receiver", func(c *Config) { c.Routes = []Route{{Receiver: "sre"}} }, `route 0 uses undefined receiver "sre"`},
+		{"missing algorithm script", func(c *Config) { c.Checks[0].Algorithm = "missing" }, `check "API" algorithm script ` + filepath.Join(dir, "missing.py") + ` not found`},
+		{"missing action script", func(c *Config) { c.Checks[0].Actions[0].Action = "missing" }, `check "API" action "Fix" script ` + filepath.Join(dir, "missing.py") + ` not found`},
+		{"missing working dir", func(c *Config) { c.BaseWorkingDir = filepath.Join(dir, "missing") }, `base_working_dir "` + filepath.Join(dir, "missing") + `" is not a directory`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := validConfig(dir)
+			tt.modify(conf)
+			err := validateConfig(conf, true)
+			if tt.expected == "" {
+				if err != nil {
+					t.Fatalf("Expected a valid config, got %v", err)
+				}
+				return
+			}
+			if errs := unjoin(err); len(errs) != 1 || !strings.Contains(errs[0].Error(), tt.expected) {
+				t.Fatalf("Expected the single error %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestValidateConfigReportsAllErrors(t *testing.T) {
+	conf := validConfig(t.TempDir())
+	conf.Datasources[0].URL = "localhost"
+	conf.Checks[0].Interval.Duration = 0
+	conf.Checks = append(conf.Checks, conf.Checks[0])
+	if errs := unjoin(validateConfig(conf, false)); len(errs) != 4 {
+		t.Fatalf("Expected 4 errors, got %d: %v", len(errs), errs)
+	}
+}
+
+func TestValidateConfigSkipsFilesAtRuntime(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "missing")
+	if err := validateConfig(validConfig(dir), false); err != nil {
+		t.Fatalf("Expected missing scripts and directories to be left to runtime, got %v", err)
+	}
+	if errs := unjoin(validateConfig(validConfig(dir), true)); len(errs) != 3 {
+		t.Fatalf("Expected the missing directory and 2 scripts to be reported, got %v", errs)
+	}
+}
+
+func TestSampleConfigIsValid(t *testing.T) {
+	conf, err := loadConfig("../../algomon.json")
+	if err != nil {
+		t.Fatalf("Could not load the sample config:%v", err)
+	}
+	if err := validateConfig(conf, false); err != nil {
+		t.Fatalf("Expected the sample config to be valid, got %v", err)
+	}
+}
+
+func TestUnjoin(t *testing.T) {
+	a, b, c := errors.New("a"), errors.New("b"), errors.New("c")
+	tests := []struct {
+		err      error
+		expected []error
+	}{
+		{a, []error{a}},
+		{errors.Join(a, b), []error{a, b}},
+		{errors.Join(a, errors.Join(b, c)), []error{a, b, c}},
+		{fmt.Errorf("wrapped: %w", a), []error{fmt.Errorf("wrapped: %w", a)}},
+	}
+	for _, tt := range tests {
+		errs := unjoin(tt.err)
+		if len(errs) != len(tt.expected) {
+			t.Fatalf("Expected %v, got %v", tt.expected, errs)
+		}
+		for i := range errs {
+			if errs[i].Error() != tt.expected[i].Error() {
+				t.Fatalf("Expected %v, got %v", tt.expected, errs)
+			}
+		}
+	}
+}
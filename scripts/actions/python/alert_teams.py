@@ -7,14 +7,16 @@ import json
 def sendToTeams(inputs, params):
     '''sends alert to teams channel'''
     headers={"Content-Type": "application/json"}
-    username = params.get("username")
-    title = username + " " + params.get("name")
+    username = params.get("username", "algomon")
+    check = inputs["check"]
+    state = inputs["state"]
+    title = "{} [{}] {}".format(username, state.upper(), check["name"])
     message = {
-        "check_output": inputs
+        "check_output": inputs["result"] or inputs["output"]["combined_out"]
     }
     payload = {
         "summary": title,
-        "themeColor": "#FF0000",
+        "themeColor": "#2EB67D" if state == "resolved" else "#FF0000",
         "sections": [{
             "activityTitle": title,
             "activitySubtitle": formatMessage(message),
@@ -4,6 +4,16 @@ import argparse
 import json
 
 def applyAction(inputs, params):
+    """
+        inputs is the payload of the check run that triggered the action:
+        - inputs["action_name"]: The name of the action being dispatched
+        - inputs["state"]: "firing" or "resolved"
+        - inputs["previous_status"]: The status of the previous run of the check
+        - inputs["check"]: The check definition, its name, labels, inputs, algorithm and params
+        - inputs["inputs"]: The measured inputs the algorithm was applied to
+        - inputs["output"]: The output of the algorithm, its status, rc, error and combined_out
+        - inputs["result"]: combined_out decoded, if the algorithm printed JSON
+    """
     print(inputs)
     print(params)
 
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"strconv"
+	"time"
 
 	"github.com/boltdb/bolt"
 	log "github.com/charmbracelet/log"
@@ -29,6 +31,10 @@ func NewBoltStore(f string, logger *log.Logger) (*BoltStore, error) {
 		db:     db,
 		logger: logger,
 	}
+	if err := db.Update(indexFailedActions); err != nil {
+		db.Close()
+		return nil, err
+	}
 	return s, nil
 }
 
@@ -108,6 +114,16 @@ func (s *BoltStore) GetChecksStatus(ctx context.Context) ([]algochecks.Output, e
 		if err != nil {
 			return result, err
 		}
+		ack, err := s.GetAck(ctx, name)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return result, err
+		}
+		out.Ack = ack
+		alert, err := s.GetAlertState(ctx, name)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return result, err
+		}
+		out.Alert = alert
 		result = append(result, out)
 	}
 	return result, err
@@ -158,7 +174,7 @@ func (s *BoltStore) GetNamedCheckFailures(ctx context.Context, name string, limi
 			if err != nil {
 				return err
 			}
-			if output.Status == algochecks.StatusFailed {
+			if output.Failing() {
 				outputs = append(outputs, output)
 				count += 1
 			}
@@ -207,20 +223,374 @@ func (s *BoltStore) GetNamedCheck(ctx context.Context, name string, limit int) (
 	return allOutputs, err
 }
 
-func (s *BoltStore) PutAction(ctx context.Context, checkName string, action *actions.ActionMeta, output *actions.Output) (key string, err error) {
-	key = checkName + "_" + action.Name + "_"
-	err = s.db.Update(func(tx *bolt.Tx) error {
+// ActionKey is the storage key of the output of an action dispatched at the given time
+func ActionKey(checkName string, actionName string, at time.Time) string {
+	return checkName + "_" + actionName + "_" + strconv.FormatInt(at.Unix(), 10)
+}
+
+func (s *BoltStore) PutAction(ctx context.Context, key string, output *actions.Output) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		bucket, err := tx.CreateBucketIfNotExists([]byte("actions"))
 		if err != nil {
 			return err
 		}
-		key += strconv.FormatInt(output.Timestamp.Unix(), 10)
 		val, err := json.Marshal(output)
 		if err != nil {
 			return fmt.Errorf("Error Marshalling Output to JSON: %v", err)
 		}
-		bucket.Put([]byte(key), val)
+		return bucket.Put([]byte(key), val)
+	})
+}
+
+func (s *BoltStore) PutAck(ctx context.Context, name string, ack *algochecks.Acknowledgement) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte("acks"))
+		if err != nil {
+			return err
+		}
+		val, err := json.Marshal(ack)
+		if err != nil {
+			return fmt.Errorf("Error Marshalling Ack to JSON: %v", err)
+		}
+		return bucket.Put([]byte(name), val)
+	})
+}
+
+// GetAck returns the acknowledgement of the named check, or ErrNotFound if it is not acknowledged
+func (s *BoltStore) GetAck(ctx context.Context, name string) (ack *algochecks.Acknowledgement, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("acks"))
+		if bucket == nil {
+			return ErrNotFound
+		}
+		val := bucket.Get([]byte(name))
+		if val == nil {
+			return ErrNotFound
+		}
+		ack = &algochecks.Acknowledgement{}
+		if err := json.Unmarshal(val, ack); err != nil {
+			return fmt.Errorf("Could not unmarshal ack JSON:%v", err)
+		}
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	return ack, nil
+}
+
+func (s *BoltStore) DeleteAck(ctx context.Context, name string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("acks"))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Delete([]byte(name))
+	})
+}
+
+func (s *BoltStore) PutAlertState(ctx context.Context, name string, state *algochecks.AlertState) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte("alerts"))
+		if err != nil {
+			return err
+		}
+		val, err := json.Marshal(state)
+		if err != nil {
+			return fmt.Errorf("Error Marshalling Alert State to JSON: %v", err)
+		}
+		return bucket.Put([]byte(name), val)
+	})
+}
+
+// GetAlertState returns the alert state of the named check, or ErrNotFound if it is not firing
+func (s *BoltStore) GetAlertState(ctx context.Context, name string) (state *algochecks.AlertState, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("alerts"))
+		if bucket == nil {
+			return ErrNotFound
+		}
+		val := bucket.Get([]byte(name))
+		if val == nil {
+			return ErrNotFound
+		}
+		state = &algochecks.AlertState{}
+		if err := json.Unmarshal(val, state); err != nil {
+			return fmt.Errorf("Could not unmarshal alert state JSON:%v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (s *BoltStore) DeleteAlertState(ctx context.Context, name string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("alerts"))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Delete([]byte(name))
+	})
+}
+
+// FailedAction is an action delivery that failed after all of its retries
+type FailedAction struct {
+	ID        string             `json:"id"`
+	CheckName string             `json:"check_name"`
+	Action    actions.ActionMeta `json:"action"`
+	Payload   *actions.Payload   `json:"payload"`
+	Error     string             `json:"error"`
+	Attempts  int                `json:"attempts"`
+	FailedAt  time.Time          `json:"failed_at"`
+	// Members are the checks of a grouped notification still firing, CheckName being the first
+	Members []string `json:"members,omitempty"`
+}
+
+// checks returns the checks the failed action is indexed under
+func (f *FailedAction) checks() []string {
+	if len(f.Members) > 0 {
+		return f.Members
+	}
+	return []string{f.CheckName}
+}
+
+// indexFailedActions builds the index of the failed actions stored before it existed
+func indexFailedActions(tx *bolt.Tx) error {
+	bucket := tx.Bucket([]byte("failed_actions"))
+	if bucket == nil || tx.Bucket([]byte("failed_actions_by_check")) != nil {
+		return nil
+	}
+	return bucket.ForEach(func(k []byte, v []byte) error {
+		f := FailedAction{}
+		if err := json.Unmarshal(v, &f); err != nil {
+			return fmt.Errorf("Could not unmarshal failed action JSON:%v", err)
+		}
+		return indexFailedAction(tx, &f, true)
+	})
+}
+
+// indexFailedAction adds the failed action to the index of its checks, or removes it. The index
+// holds a bucket per check mapping the ids of its failed actions to their action names, so the
+// failed actions of a check are found without decoding the whole queue.
+func indexFailedAction(tx *bolt.Tx, f *FailedAction, add bool) error {
+	index, err := tx.CreateBucketIfNotExists([]byte("failed_actions_by_check"))
+	if err != nil {
+		return err
+	}
+	for _, name := range f.checks() {
+		bucket, err := index.CreateBucketIfNotExists([]byte(name))
+		if err != nil {
+			return err
+		}
+		if add {
+			err = bucket.Put([]byte(f.ID), []byte(f.Action.Name))
+		} else {
+			err = bucket.Delete([]byte(f.ID))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PutFailedAction stores the failed action, assigning it an ID if it does not have one yet
+func (s *BoltStore) PutFailedAction(ctx context.Context, failed *FailedAction) (key string, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte("failed_actions"))
+		if err != nil {
+			return err
+		}
+		if failed.ID == "" {
+			seq, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+			// Zero padded so that keys sort in insertion order
+			failed.ID = fmt.Sprintf("%020d", seq)
+		} else if previous := bucket.Get([]byte(failed.ID)); previous != nil {
+			old := FailedAction{}
+			if err := json.Unmarshal(previous, &old); err == nil {
+				if err := indexFailedAction(tx, &old, false); err != nil {
+					return err
+				}
+			}
+		}
+		key = failed.ID
+		val, err := json.Marshal(failed)
+		if err != nil {
+			return fmt.Errorf("Error Marshalling Failed Action to JSON: %v", err)
+		}
+		if err := bucket.Put([]byte(key), val); err != nil {
+			return err
+		}
+		return indexFailedAction(tx, failed, true)
+	})
+	return key, err
+}
+
+func (s *BoltStore) GetFailedAction(ctx context.Context, id string) (failed *FailedAction, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("failed_actions"))
+		if bucket == nil {
+			return ErrNotFound
+		}
+		val := bucket.Get([]byte(id))
+		if val == nil {
+			return ErrNotFound
+		}
+		failed = &FailedAction{}
+		if err := json.Unmarshal(val, failed); err != nil {
+			return fmt.Errorf("Could not unmarshal failed action JSON:%v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return failed, nil
+}
+
+// GetFailedActions returns all failed actions, oldest first
+func (s *BoltStore) GetFailedActions(ctx context.Context) ([]FailedAction, error) {
+	failed := []FailedAction{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("failed_actions"))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k []byte, v []byte) error {
+			f := FailedAction{}
+			if err := json.Unmarshal(v, &f); err != nil {
+				return fmt.Errorf("Could not unmarshal failed action JSON:%v", err)
+			}
+			failed = append(failed, f)
+			return nil
+		})
+	})
+	return failed, err
+}
+
+// GetCheckFailedActions returns the failed actions of the check with the given action names, or
+// all of them if no names are given, oldest first
+func (s *BoltStore) GetCheckFailedActions(ctx context.Context, checkName string, actionNames ...string) ([]FailedAction, error) {
+	failed := []FailedAction{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("failed_actions"))
+		index := tx.Bucket([]byte("failed_actions_by_check"))
+		if bucket == nil || index == nil || index.Bucket([]byte(checkName)) == nil {
+			return nil
+		}
+		return index.Bucket([]byte(checkName)).ForEach(func(id []byte, action []byte) error {
+			if len(actionNames) > 0 && !slices.Contains(actionNames, string(action)) {
+				return nil
+			}
+			val := bucket.Get(id)
+			if val == nil {
+				return nil
+			}
+			f := FailedAction{}
+			if err := json.Unmarshal(val, &f); err != nil {
+				return fmt.Errorf("Could not unmarshal failed action JSON:%v", err)
+			}
+			failed = append(failed, f)
+			return nil
+		})
+	})
+	return failed, err
+}
+
+func (s *BoltStore) DeleteFailedAction(ctx context.Context, id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("failed_actions"))
+		if bucket == nil {
+			return ErrNotFound
+		}
+		val := bucket.Get([]byte(id))
+		if val == nil {
+			return ErrNotFound
+		}
+		f := FailedAction{}
+		if err := json.Unmarshal(val, &f); err == nil {
+			if err := indexFailedAction(tx, &f, false); err != nil {
+				return err
+			}
+		}
+		return bucket.Delete([]byte(id))
+	})
+}
+
+var RemediationRunning = "running"
+var RemediationExecuted = "executed"
+var RemediationFailed = "failed"
+var RemediationDryRun = "dry_run"
+var RemediationRateLimited = "rate_limited"
+var RemediationCoolingDown = "cooling_down"
+
+// RemediationRecord is the audit entry of a remediation action being considered for execution
+type RemediationRecord struct {
+	ID         string         `json:"id"`
+	CheckName  string         `json:"check_name"`
+	ActionName string         `json:"action_name"`
+	Actioner   string         `json:"actioner"`
+	Action     string         `json:"action"`
+	Outcome    string         `json:"outcome"`
+	Reason     string         `json:"reason"`
+	Timestamp  time.Time      `json:"timestamp"`
+	Output     actions.Output `json:"output"`
+	Error      string         `json:"error"`
+}
+
+// PutRemediation stores the audit record, assigning it an ID if it does not have one yet
+func (s *BoltStore) PutRemediation(ctx context.Context, record *RemediationRecord) (key string, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte("remediations"))
+		if err != nil {
+			return err
+		}
+		if record.ID == "" {
+			seq, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+			record.ID = fmt.Sprintf("%020d", seq)
+		}
+		key = record.ID
+		val, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("Error Marshalling Remediation to JSON: %v", err)
+		}
+		return bucket.Put([]byte(key), val)
+	})
 	return key, err
 }
+
+// GetRemediations returns the audit records since the given time, newest first. Empty check or
+// action names match all records.
+func (s *BoltStore) GetRemediations(ctx context.Context, checkName string, actionName string, since time.Time) ([]RemediationRecord, error) {
+	records := []RemediationRecord{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("remediations"))
+		if bucket == nil {
+			return nil
+		}
+		cur := bucket.Cursor()
+		for k, v := cur.Last(); k != nil; k, v = cur.Prev() {
+			record := RemediationRecord{}
+			if err := json.Unmarshal(v, &record); err != nil {
+				return fmt.Errorf("Could not unmarshal remediation JSON:%v", err)
+			}
+			if record.Timestamp.Before(since) {
+				break
+			}
+			if (checkName == "" || record.CheckName == checkName) && (actionName == "" || record.ActionName == actionName) {
+				records = append(records, record)
+			}
+		}
+		return nil
+	})
+	return records, err
+}
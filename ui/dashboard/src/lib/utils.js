@@ -49,6 +49,8 @@ export function getMinutesSinceDate(date) {
 export function getStatusIcon(status) {
 	if (status === 'SUCCESSFUL') {
 		return "<i class='fa-solid fa-check' style='color: #63E6BE;'></i>";
+	} else if (status === 'SKIPPED') {
+		return "<i class='fa-solid fa-forward' style='color: #9ca3af;'></i>";
 	} else {
 		return "<i class='fa-solid fa-xmark' style='color: #df0c0c;'></i>";
 	}